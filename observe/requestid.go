@@ -0,0 +1,26 @@
+package observe
+
+import "context"
+
+// RequestIDKey is the gRPC metadata and pubsub attribute key used to propagate
+// request IDs. Over HTTP, the ID is sent in the X-Request-Id header.
+const RequestIDKey = "x-request-id"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of the context carrying the given request ID.
+// The gizmo kit server adds the ID of every inbound request to its context, and
+// the kit clients and pubsub publishers propagate it on outbound calls.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by the context, or an empty string.
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		// some publishers historically accepted a nil context
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
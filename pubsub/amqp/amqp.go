@@ -0,0 +1,410 @@
+package amqp // import "github.com/NYTimes/gizmo/pubsub/amqp"
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/NYTimes/gizmo/observe"
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/golang/protobuf/proto"
+	amqp091 "github.com/rabbitmq/amqp091-go"
+	"golang.org/x/net/context"
+)
+
+var (
+	// ErrNotConfirmed is returned when the broker negatively acknowledges
+	// a published message.
+	ErrNotConfirmed = errors.New("message was not confirmed by the broker")
+	// ErrConfirmTimeout is returned when the broker does not confirm a
+	// published message within the configured ConfirmTimeout.
+	ErrConfirmTimeout = errors.New("timed out waiting for publish confirmation")
+)
+
+// Publisher publishes messages to an AMQP exchange with publisher confirms
+// enabled. If the connection to the broker is lost, the Publisher will
+// attempt to reconnect on the next publish.
+type Publisher struct {
+	cfg  Config
+	dial func(string) (connection, error)
+
+	mu       sync.Mutex
+	conn     connection
+	ch       channel
+	confirms chan amqp091.Confirmation
+	closed   chan *amqp091.Error
+}
+
+var _ pubsub.Publisher = &Publisher{}
+var _ pubsub.MultiPublisher = &Publisher{}
+
+// NewPublisher will connect to the broker and return a new Publisher.
+func NewPublisher(cfg Config) (*Publisher, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("broker url is required")
+	}
+	defaultConfig(&cfg)
+	p := &Publisher{cfg: cfg, dial: dial}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.connect(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Publisher) connect() error {
+	conn, err := p.dial(p.cfg.URL)
+	if err != nil {
+		return err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if err = ch.Confirm(false); err != nil {
+		conn.Close()
+		return err
+	}
+	p.conn, p.ch = conn, ch
+	p.confirms = ch.NotifyPublish(make(chan amqp091.Confirmation, 1))
+	p.closed = ch.NotifyClose(make(chan *amqp091.Error, 1))
+	return nil
+}
+
+// reset will tear down the current connection so the next publish
+// will reconnect.
+func (p *Publisher) reset() error {
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn, p.ch = nil, nil
+	return err
+}
+
+// Publish will marshal the proto message and publish it to the exchange.
+func (p *Publisher) Publish(ctx context.Context, key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(ctx, key, mb)
+}
+
+// PublishRaw will publish the byte array to the exchange using the key as
+// the routing key and block until the broker confirms the message. The request
+// ID of the context, if any, is added to the message headers.
+func (p *Publisher) PublishRaw(ctx context.Context, key string, m []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ch != nil {
+		select {
+		case <-p.closed:
+			p.reset()
+		default:
+		}
+	}
+	if p.ch == nil {
+		if err := p.connect(); err != nil {
+			return err
+		}
+	}
+
+	msg := amqp091.Publishing{
+		Body:         m,
+		DeliveryMode: amqp091.Persistent,
+		Timestamp:    time.Now(),
+	}
+	if id := observe.RequestID(ctx); id != "" {
+		msg.Headers = amqp091.Table{observe.RequestIDKey: id}
+	}
+	err := p.ch.Publish(p.cfg.Exchange, key, false, false, msg)
+	if err != nil {
+		p.reset()
+		return err
+	}
+
+	// any abandoned confirmation would be read by the next publish, so
+	// the connection is reset whenever we stop waiting early.
+	select {
+	case c, ok := <-p.confirms:
+		if !ok {
+			p.reset()
+			return errors.New("channel closed before publish was confirmed")
+		}
+		if !c.Ack {
+			return ErrNotConfirmed
+		}
+		return nil
+	case <-ctx.Done():
+		p.reset()
+		return ctx.Err()
+	case <-time.After(p.cfg.ConfirmTimeout):
+		p.reset()
+		return ErrConfirmTimeout
+	}
+}
+
+// PublishMulti will publish multiple messages to the exchange.
+func (p *Publisher) PublishMulti(ctx context.Context, keys []string, messages []proto.Message) error {
+	if len(keys) != len(messages) {
+		return errors.New("keys and messages must be equal length")
+	}
+
+	a := make([][]byte, len(messages))
+	for i := range messages {
+		b, err := proto.Marshal(messages[i])
+		if err != nil {
+			return err
+		}
+		a[i] = b
+	}
+	return p.PublishMultiRaw(ctx, keys, a)
+}
+
+// PublishMultiRaw will publish multiple raw byte array messages to the exchange.
+func (p *Publisher) PublishMultiRaw(ctx context.Context, keys []string, messages [][]byte) error {
+	if len(keys) != len(messages) {
+		return errors.New("keys and messages must be equal length")
+	}
+
+	for i := range messages {
+		if err := p.PublishRaw(ctx, keys[i], messages[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop will close the connection to the broker.
+func (p *Publisher) Stop() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.reset()
+}
+
+// Subscriber consumes messages from an AMQP queue via the pubsub.Subscriber
+// interface. If the connection to the broker is lost, the Subscriber will
+// reconnect and resume consuming. Messages delivered before a reconnect can
+// no longer be acknowledged and will be redelivered by the broker.
+type Subscriber struct {
+	cfg  Config
+	dial func(string) (connection, error)
+
+	conn connection
+
+	mu   sync.Mutex
+	stop chan chan error
+	done chan struct{}
+
+	err error
+}
+
+// NewSubscriber will return a new Subscriber for the configured queue. The
+// connection to the broker is established on Start.
+func NewSubscriber(cfg Config) (*Subscriber, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("broker url is required")
+	}
+	if cfg.Queue == "" {
+		return nil, errors.New("queue name is required")
+	}
+	defaultConfig(&cfg)
+	return &Subscriber{
+		cfg:  cfg,
+		dial: dial,
+		stop: make(chan chan error),
+	}, nil
+}
+
+func (s *Subscriber) consume() (<-chan amqp091.Delivery, error) {
+	conn, err := s.dial(s.cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err = ch.Qos(s.cfg.Prefetch, 0, false); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	deliveries, err := ch.Consume(s.cfg.Queue, s.cfg.ConsumerTag, false, false, false, false, nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	s.conn = conn
+	return deliveries, nil
+}
+
+func (s *Subscriber) close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// Start will connect to the broker and start consuming from the queue,
+// emitting any messages to the returned channel. If the Subscriber is unable
+// to reconnect after MaxReconnects attempts, it will populate the Err() error
+// and close the returned channel.
+func (s *Subscriber) Start() <-chan pubsub.SubscriberMessage {
+	output := make(chan pubsub.SubscriberMessage)
+	s.mu.Lock()
+	s.done = make(chan struct{})
+	s.mu.Unlock()
+
+	go func(s *Subscriber, output chan pubsub.SubscriberMessage) {
+		defer close(s.done)
+		defer close(output)
+
+		var failures int
+		for {
+			deliveries, err := s.consume()
+			if err != nil {
+				failures++
+				if s.cfg.MaxReconnects > 0 && failures > s.cfg.MaxReconnects {
+					s.err = err
+					return
+				}
+				pubsub.Log.Warnf("unable to consume from queue %s: %s", s.cfg.Queue, err)
+			} else {
+				failures = 0
+				for deliveries != nil {
+					select {
+					case exit := <-s.stop:
+						exit <- s.close()
+						return
+					case d, ok := <-deliveries:
+						if !ok {
+							pubsub.Log.Warnf("lost connection consuming from queue %s", s.cfg.Queue)
+							s.close()
+							deliveries = nil
+							continue
+						}
+						select {
+						case output <- &SubMessage{delivery: d, RoutingKey: d.RoutingKey, Headers: d.Headers}:
+						case exit := <-s.stop:
+							exit <- s.close()
+							return
+						}
+					}
+				}
+			}
+
+			select {
+			case exit := <-s.stop:
+				exit <- nil
+				return
+			case <-time.After(s.cfg.ReconnectDelay):
+			}
+		}
+	}(s, output)
+	return output
+}
+
+// Err will contain any error the Subscriber has encountered while processing.
+func (s *Subscriber) Err() error {
+	return s.err
+}
+
+// Stop will block until the consumer has stopped consuming messages and
+// return any error seen while closing the connection.
+func (s *Subscriber) Stop() error {
+	s.mu.Lock()
+	done := s.done
+	s.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+
+	exit := make(chan error)
+	select {
+	case s.stop <- exit:
+		return <-exit
+	case <-done:
+		return nil
+	}
+}
+
+// SubMessage is the AMQP implementation of pubsub.SubscriberMessage.
+type SubMessage struct {
+	delivery amqp091.Delivery
+
+	// RoutingKey is the routing key the message was published with.
+	RoutingKey string
+	// Headers contains the AMQP headers of the message.
+	Headers amqp091.Table
+}
+
+// Message will return the body of the AMQP delivery.
+func (m *SubMessage) Message() []byte {
+	return m.delivery.Body
+}
+
+// ExtendDoneDeadline has no effect on SubMessage as AMQP deliveries
+// do not expire.
+func (m *SubMessage) ExtendDoneDeadline(time.Duration) error {
+	return nil
+}
+
+// Done will acknowledge the delivery.
+func (m *SubMessage) Done() error {
+	return m.delivery.Ack(false)
+}
+
+// Reject will reject the delivery. If requeue is true, the broker will
+// attempt to redeliver the message, otherwise it will be discarded or
+// dead-lettered depending on the queue's configuration.
+func (m *SubMessage) Reject(requeue bool) error {
+	return m.delivery.Reject(requeue)
+}
+
+// interfaces and types to make this more testable
+type (
+	connection interface {
+		Channel() (channel, error)
+		Close() error
+	}
+	channel interface {
+		Confirm(noWait bool) error
+		NotifyPublish(chan amqp091.Confirmation) chan amqp091.Confirmation
+		NotifyClose(chan *amqp091.Error) chan *amqp091.Error
+		Publish(exchange, key string, mandatory, immediate bool, msg amqp091.Publishing) error
+		Qos(prefetchCount, prefetchSize int, global bool) error
+		Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp091.Table) (<-chan amqp091.Delivery, error)
+	}
+
+	connectionImpl struct {
+		Conn *amqp091.Connection
+	}
+)
+
+func dial(url string) (connection, error) {
+	conn, err := amqp091.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	return connectionImpl{conn}, nil
+}
+
+func (c connectionImpl) Channel() (channel, error) {
+	ch, err := c.Conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+func (c connectionImpl) Close() error {
+	return c.Conn.Close()
+}
@@ -0,0 +1,258 @@
+package amqp
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	amqp091 "github.com/rabbitmq/amqp091-go"
+	"golang.org/x/net/context"
+)
+
+func TestPublisher(t *testing.T) {
+	ch := &testChannel{}
+	conns := &testDialer{channels: []*testChannel{ch}}
+	pub := &Publisher{cfg: Config{Exchange: "events", ConfirmTimeout: time.Second}, dial: conns.dial}
+
+	err := pub.PublishMultiRaw(context.Background(),
+		[]string{"key.one", "key.two"}, [][]byte{[]byte("one"), []byte("two")})
+	if err != nil {
+		t.Fatalf("unexpected error publishing: %s", err)
+	}
+
+	if len(ch.published) != 2 {
+		t.Fatalf("expected 2 published messages, got %d", len(ch.published))
+	}
+	for i, want := range []string{"one", "two"} {
+		got := ch.published[i]
+		if got.exchange != "events" {
+			t.Errorf("expected exchange %q, got %q", "events", got.exchange)
+		}
+		if string(got.msg.Body) != want {
+			t.Errorf("expected body %q, got %q", want, got.msg.Body)
+		}
+	}
+	if ch.published[1].key != "key.two" {
+		t.Errorf("expected routing key %q, got %q", "key.two", ch.published[1].key)
+	}
+	if !ch.confirm {
+		t.Error("expected channel to be put in confirm mode")
+	}
+
+	ch.nack = true
+	if err := pub.PublishRaw(context.Background(), "key", nil); err != ErrNotConfirmed {
+		t.Errorf("expected ErrNotConfirmed, got %v", err)
+	}
+}
+
+func TestNewPublisherDialError(t *testing.T) {
+	pub, err := NewPublisher(Config{URL: "amqp://127.0.0.1:1/"})
+	if err == nil {
+		t.Fatal("expected an error dialing an unreachable broker")
+	}
+	if pub != nil {
+		t.Error("expected no publisher on error")
+	}
+}
+
+func TestPublisherReconnect(t *testing.T) {
+	first, second := &testChannel{}, &testChannel{}
+	conns := &testDialer{channels: []*testChannel{first, second}}
+	pub := &Publisher{cfg: Config{ConfirmTimeout: time.Second}, dial: conns.dial}
+
+	if err := pub.PublishRaw(context.Background(), "key", []byte("one")); err != nil {
+		t.Fatalf("unexpected error publishing: %s", err)
+	}
+
+	// simulate a broker restart
+	first.shutdown()
+
+	if err := pub.PublishRaw(context.Background(), "key", []byte("two")); err != nil {
+		t.Fatalf("unexpected error publishing after reconnect: %s", err)
+	}
+	if len(second.published) != 1 || string(second.published[0].msg.Body) != "two" {
+		t.Errorf("expected message to be published on the new channel")
+	}
+	if conns.dials != 2 {
+		t.Errorf("expected 2 dials, got %d", conns.dials)
+	}
+}
+
+func TestSubscriber(t *testing.T) {
+	ack := &testAcknowledger{}
+	first := &testChannel{deliveries: make(chan amqp091.Delivery, 2)}
+	first.deliveries <- amqp091.Delivery{Acknowledger: ack, DeliveryTag: 1, RoutingKey: "a", Body: []byte("one")}
+	first.deliveries <- amqp091.Delivery{Acknowledger: ack, DeliveryTag: 2, RoutingKey: "b", Body: []byte("two")}
+	second := &testChannel{deliveries: make(chan amqp091.Delivery, 1)}
+	second.deliveries <- amqp091.Delivery{Acknowledger: ack, DeliveryTag: 1, RoutingKey: "c", Body: []byte("three")}
+
+	conns := &testDialer{channels: []*testChannel{first, second}}
+	sub := &Subscriber{
+		cfg:  Config{Queue: "events", Prefetch: 5, ReconnectDelay: time.Millisecond},
+		dial: conns.dial,
+		stop: make(chan chan error),
+	}
+
+	msgs := sub.Start()
+	m := <-msgs
+	if string(m.Message()) != "one" || m.(*SubMessage).RoutingKey != "a" {
+		t.Errorf("unexpected first message: %q", m.Message())
+	}
+	if err := m.Done(); err != nil {
+		t.Errorf("unexpected error acking: %s", err)
+	}
+	m = <-msgs
+	if err := m.(*SubMessage).Reject(true); err != nil {
+		t.Errorf("unexpected error rejecting: %s", err)
+	}
+
+	// simulate a broker restart
+	close(first.deliveries)
+
+	m = <-msgs
+	if string(m.Message()) != "three" {
+		t.Errorf("expected message after reconnect, got %q", m.Message())
+	}
+
+	if err := sub.Stop(); err != nil {
+		t.Errorf("unexpected error stopping: %s", err)
+	}
+	if _, ok := <-msgs; ok {
+		t.Error("expected channel to be closed after Stop")
+	}
+
+	if first.prefetch != 5 || first.queue != "events" {
+		t.Errorf("unexpected consume settings: prefetch %d, queue %q", first.prefetch, first.queue)
+	}
+	if len(ack.acked) != 1 || ack.acked[0] != 1 {
+		t.Errorf("expected delivery 1 to be acked, got %v", ack.acked)
+	}
+	if len(ack.requeued) != 1 || ack.requeued[0] != 2 {
+		t.Errorf("expected delivery 2 to be requeued, got %v", ack.requeued)
+	}
+}
+
+func TestSubscriberMaxReconnects(t *testing.T) {
+	wantErr := errors.New("connection refused")
+	conns := &testDialer{err: wantErr}
+	sub := &Subscriber{
+		cfg:  Config{Queue: "events", ReconnectDelay: time.Millisecond, MaxReconnects: 2},
+		dial: conns.dial,
+		stop: make(chan chan error),
+	}
+
+	if _, ok := <-sub.Start(); ok {
+		t.Fatal("expected channel to be closed")
+	}
+	if sub.Err() != wantErr {
+		t.Errorf("expected error %v, got %v", wantErr, sub.Err())
+	}
+	if conns.dials != 3 {
+		t.Errorf("expected 3 dials, got %d", conns.dials)
+	}
+	if err := sub.Stop(); err != nil {
+		t.Errorf("unexpected error stopping: %s", err)
+	}
+}
+
+type (
+	testDialer struct {
+		channels []*testChannel
+		err      error
+		dials    int
+	}
+
+	testConnection struct {
+		ch *testChannel
+	}
+
+	testPublishing struct {
+		exchange, key string
+		msg           amqp091.Publishing
+	}
+
+	testChannel struct {
+		mu        sync.Mutex
+		confirm   bool
+		nack      bool
+		published []testPublishing
+		confirms  chan amqp091.Confirmation
+		closes    chan *amqp091.Error
+
+		prefetch   int
+		queue      string
+		deliveries chan amqp091.Delivery
+	}
+
+	testAcknowledger struct {
+		acked, requeued []uint64
+	}
+)
+
+func (d *testDialer) dial(string) (connection, error) {
+	d.dials++
+	if d.err != nil {
+		return nil, d.err
+	}
+	ch := d.channels[0]
+	d.channels = d.channels[1:]
+	return &testConnection{ch}, nil
+}
+
+func (c *testConnection) Channel() (channel, error) { return c.ch, nil }
+func (c *testConnection) Close() error              { return nil }
+
+func (c *testChannel) Confirm(bool) error {
+	c.confirm = true
+	return nil
+}
+
+func (c *testChannel) NotifyPublish(ch chan amqp091.Confirmation) chan amqp091.Confirmation {
+	c.confirms = ch
+	return ch
+}
+
+func (c *testChannel) NotifyClose(ch chan *amqp091.Error) chan *amqp091.Error {
+	c.closes = ch
+	return ch
+}
+
+func (c *testChannel) shutdown() {
+	close(c.closes)
+	close(c.confirms)
+}
+
+func (c *testChannel) Publish(exchange, key string, _, _ bool, msg amqp091.Publishing) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.published = append(c.published, testPublishing{exchange, key, msg})
+	c.confirms <- amqp091.Confirmation{DeliveryTag: uint64(len(c.published)), Ack: !c.nack}
+	return nil
+}
+
+func (c *testChannel) Qos(prefetch, _ int, _ bool) error {
+	c.prefetch = prefetch
+	return nil
+}
+
+func (c *testChannel) Consume(queue, _ string, _, _, _, _ bool, _ amqp091.Table) (<-chan amqp091.Delivery, error) {
+	c.queue = queue
+	return c.deliveries, nil
+}
+
+func (a *testAcknowledger) Ack(tag uint64, _ bool) error {
+	a.acked = append(a.acked, tag)
+	return nil
+}
+
+func (a *testAcknowledger) Nack(tag uint64, _ bool, requeue bool) error {
+	return a.Reject(tag, requeue)
+}
+
+func (a *testAcknowledger) Reject(tag uint64, requeue bool) error {
+	if requeue {
+		a.requeued = append(a.requeued, tag)
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/NYTimes/gizmo/observe"
 	"github.com/NYTimes/gizmo/pubsub"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -28,6 +29,9 @@ type key int
 // different integer values.
 const msgAttrsKey key = 0
 
+// maxMessageAttributes is the number of message attributes allowed by SNS.
+const maxMessageAttributes = 10
+
 // publisher will accept AWS credentials and an SNS topic name
 // and it will emit any publish events to it.
 type publisher struct {
@@ -88,7 +92,9 @@ func (p *publisher) Publish(ctx context.Context, key string, m proto.Message) er
 
 // PublishRaw will emit the byte array to the SNS topic.
 // The key will be used as the SNS message subject.
-// You can use func WithMessageAttributes to set SNS message attributes for the message
+// You can use func WithMessageAttributes to set SNS message attributes for the message.
+// The request ID of the context, if any, is added as the x-request-id attribute
+// unless the message already has the maximum of 10 attributes SNS allows.
 func (p *publisher) PublishRaw(ctx context.Context, key string, m []byte) error {
 	msg := &sns.PublishInput{
 		TopicArn: &p.topic,
@@ -99,6 +105,20 @@ func (p *publisher) PublishRaw(ctx context.Context, key string, m []byte) error
 	if v, ok := ctx.Value(msgAttrsKey).(map[string]*sns.MessageAttributeValue); ok {
 		msg.MessageAttributes = v
 	}
+	if id := observe.RequestID(ctx); id != "" {
+		_, ok := msg.MessageAttributes[observe.RequestIDKey]
+		if !ok && len(msg.MessageAttributes) < maxMessageAttributes {
+			attrs := make(map[string]*sns.MessageAttributeValue, len(msg.MessageAttributes)+1)
+			for k, v := range msg.MessageAttributes {
+				attrs[k] = v
+			}
+			attrs[observe.RequestIDKey] = &sns.MessageAttributeValue{
+				DataType:    aws.String("String"),
+				StringValue: aws.String(id),
+			}
+			msg.MessageAttributes = attrs
+		}
+	}
 
 	_, err := p.sns.Publish(msg)
 	return err
@@ -3,9 +3,11 @@ package aws
 import (
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"reflect"
 	"testing"
 
+	"github.com/NYTimes/gizmo/observe"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/sns"
@@ -55,6 +57,53 @@ func TestPublisher(t *testing.T) {
 	}
 }
 
+func TestPublisherRequestID(t *testing.T) {
+	snstest := &TestSNSAPI{}
+	pub := &publisher{sns: snstest}
+
+	attrs := map[string]*sns.MessageAttributeValue{
+		"foo": {DataType: aws.String("String"), StringValue: aws.String("bar")},
+	}
+	ctx := WithMessageAttributes(context.Background(), attrs)
+	ctx = observe.WithRequestID(ctx, "abc123")
+	if err := pub.PublishRaw(ctx, "key", []byte("hi")); err != nil {
+		t.Fatal("PublishRaw returned an unexpected error: ", err)
+	}
+
+	got := snstest.Published[0].MessageAttributes
+	if len(got) != 2 || *got["foo"].StringValue != "bar" {
+		t.Errorf("expected the given attributes to be kept, got %v", got)
+	}
+	if id := got["x-request-id"]; id == nil || *id.StringValue != "abc123" {
+		t.Errorf("expected the request ID attribute, got %v", id)
+	}
+	if len(attrs) != 1 {
+		t.Error("expected the given attributes not to be modified")
+	}
+}
+
+func TestPublisherRequestIDAttributeLimit(t *testing.T) {
+	snstest := &TestSNSAPI{}
+	pub := &publisher{sns: snstest}
+
+	attrs := map[string]*sns.MessageAttributeValue{}
+	for i := 0; i < 10; i++ {
+		attrs[fmt.Sprint("attr-", i)] = &sns.MessageAttributeValue{
+			DataType: aws.String("String"), StringValue: aws.String("v"),
+		}
+	}
+	ctx := WithMessageAttributes(context.Background(), attrs)
+	ctx = observe.WithRequestID(ctx, "abc123")
+	if err := pub.PublishRaw(ctx, "key", []byte("hi")); err != nil {
+		t.Fatal("PublishRaw returned an unexpected error: ", err)
+	}
+
+	got := snstest.Published[0].MessageAttributes
+	if len(got) != 10 || got["x-request-id"] != nil {
+		t.Errorf("expected the request ID to be left out of a full set of attributes, got %v", got)
+	}
+}
+
 type TestSNSAPI struct {
 	// Error will be returned by the API when Publish() is called.
 	Error error
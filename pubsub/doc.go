@@ -26,7 +26,7 @@ Package pubsub contains two generic interfaces for publishing data to queues and
 
 Where a `SubscriberMessage` is an interface that gives implementations a hook for acknowledging/delete messages. Take a look at the docs for each implementation in `pubsub` to see how they behave.
 
-There are currently 3 implementations of each type of `pubsub` interfaces:
+There are currently 7 implementations of each type of `pubsub` interfaces:
 
 For pubsub via Amazon's SNS/SQS, you can use the `pubsub/aws` package.
 
@@ -34,6 +34,12 @@ For pubsub via Google's Pubsub, you can use the `pubsub/gcp` package.
 
 For pubsub via Kafka topics, you can use the `pubsub/kafka` package.
 
+For pubsub via NATS subjects and JetStream consumers, you can use the `pubsub/nats` package.
+
+For pubsub via AMQP 0-9-1 brokers such as RabbitMQ, you can use the `pubsub/amqp` package.
+
 For publishing via HTTP, you can use the `pubsub/http` package.
+
+For recording messages to and replaying them from a local JSONL file, you can use the `pubsub/file` package.
 */
 package pubsub // import "github.com/NYTimes/gizmo/pubsub"
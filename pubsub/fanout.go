@@ -0,0 +1,147 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+var errKeysMessagesLength = errors.New("keys and messages must be equal length")
+
+// FanOutMode determines when a FanOutPublisher considers a publish successful.
+type FanOutMode int
+
+const (
+	// FanOutAll requires every downstream publisher to succeed.
+	FanOutAll FanOutMode = iota
+	// FanOutAny requires at least one downstream publisher to succeed.
+	FanOutAny
+	// FanOutBestEffort never returns an error. Any failures are logged.
+	FanOutBestEffort
+)
+
+// FanOutError is returned by a FanOutPublisher when a publish did not meet
+// its FanOutMode. It holds an entry for every downstream publisher, in the
+// order they were given, with nil entries for the ones that succeeded.
+type FanOutError []error
+
+// Error will list the errors of each failed downstream publisher.
+func (e FanOutError) Error() string {
+	var msgs []string
+	for i, err := range e {
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("publisher %d: %s", i, err))
+		}
+	}
+	return "fan out publish failed: " + strings.Join(msgs, "; ")
+}
+
+// FanOutPublisher publishes every message to all of its downstream publishers
+// concurrently. It is useful for emitting the same events to several backends,
+// for example while migrating from one to another.
+type FanOutPublisher struct {
+	pubs []Publisher
+	mode FanOutMode
+}
+
+var _ MultiPublisher = &FanOutPublisher{}
+
+// NewFanOutPublisher will return a FanOutPublisher that publishes to all of the
+// given publishers with the given success semantics.
+func NewFanOutPublisher(mode FanOutMode, pubs ...Publisher) *FanOutPublisher {
+	return &FanOutPublisher{pubs: pubs, mode: mode}
+}
+
+// Publish will publish the message to every downstream publisher.
+func (f *FanOutPublisher) Publish(ctx context.Context, key string, m proto.Message) error {
+	return f.each(func(p Publisher) error {
+		return p.Publish(ctx, key, m)
+	})
+}
+
+// PublishRaw will publish the raw message to every downstream publisher.
+func (f *FanOutPublisher) PublishRaw(ctx context.Context, key string, m []byte) error {
+	return f.each(func(p Publisher) error {
+		return p.PublishRaw(ctx, key, m)
+	})
+}
+
+// PublishMulti will publish the messages to every downstream publisher, using
+// PublishMulti on those that implement MultiPublisher.
+func (f *FanOutPublisher) PublishMulti(ctx context.Context, keys []string, messages []proto.Message) error {
+	return f.each(func(p Publisher) error {
+		return publishMulti(ctx, p, keys, messages)
+	})
+}
+
+// PublishMultiRaw will publish the raw messages to every downstream publisher,
+// using PublishMultiRaw on those that implement MultiPublisher.
+func (f *FanOutPublisher) PublishMultiRaw(ctx context.Context, keys []string, messages [][]byte) error {
+	return f.each(func(p Publisher) error {
+		return publishMultiRaw(ctx, p, keys, messages)
+	})
+}
+
+func (f *FanOutPublisher) each(publish func(Publisher) error) error {
+	errs := make(FanOutError, len(f.pubs))
+	var wg sync.WaitGroup
+	for i, p := range f.pubs {
+		wg.Add(1)
+		go func(i int, p Publisher) {
+			defer wg.Done()
+			errs[i] = publish(p)
+		}(i, p)
+	}
+	wg.Wait()
+
+	var failed int
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	switch {
+	case failed == 0:
+		return nil
+	case f.mode == FanOutAny && failed < len(errs):
+		return nil
+	case f.mode == FanOutBestEffort:
+		Log.Warnf("best effort %s", errs)
+		return nil
+	}
+	return errs
+}
+
+func publishMulti(ctx context.Context, p Publisher, keys []string, messages []proto.Message) error {
+	if mp, ok := p.(MultiPublisher); ok {
+		return mp.PublishMulti(ctx, keys, messages)
+	}
+	if len(keys) != len(messages) {
+		return errKeysMessagesLength
+	}
+	for i := range messages {
+		if err := p.Publish(ctx, keys[i], messages[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func publishMultiRaw(ctx context.Context, p Publisher, keys []string, messages [][]byte) error {
+	if mp, ok := p.(MultiPublisher); ok {
+		return mp.PublishMultiRaw(ctx, keys, messages)
+	}
+	if len(keys) != len(messages) {
+		return errKeysMessagesLength
+	}
+	for i := range messages {
+		if err := p.PublishRaw(ctx, keys[i], messages[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
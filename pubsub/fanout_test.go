@@ -0,0 +1,79 @@
+package pubsub_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/pubsub/pubsubtest"
+	"golang.org/x/net/context"
+)
+
+func TestFanOutPublisher(t *testing.T) {
+	failure := errors.New("publish failed")
+
+	tests := []struct {
+		name    string
+		mode    pubsub.FanOutMode
+		errs    []error
+		wantErr bool
+	}{
+		{"all success", pubsub.FanOutAll, []error{nil, nil}, false},
+		{"all one failure", pubsub.FanOutAll, []error{nil, failure}, true},
+		{"any one failure", pubsub.FanOutAny, []error{failure, nil}, false},
+		{"any all failures", pubsub.FanOutAny, []error{failure, failure}, true},
+		{"best effort all failures", pubsub.FanOutBestEffort, []error{failure, failure}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				pubs []pubsub.Publisher
+				tps  []*pubsubtest.TestPublisher
+			)
+			for _, err := range test.errs {
+				tp := &pubsubtest.TestPublisher{GivenError: err}
+				tps = append(tps, tp)
+				pubs = append(pubs, tp)
+			}
+			fan := pubsub.NewFanOutPublisher(test.mode, pubs...)
+
+			err := fan.PublishMultiRaw(context.Background(),
+				[]string{"a", "b"}, [][]byte{[]byte("one"), []byte("two")})
+			if test.wantErr != (err != nil) {
+				t.Fatalf("expected error %t, got %v", test.wantErr, err)
+			}
+			if err != nil {
+				ferr, ok := err.(pubsub.FanOutError)
+				if !ok {
+					t.Fatalf("expected a FanOutError, got %T", err)
+				}
+				for i := range test.errs {
+					if ferr[i] != test.errs[i] {
+						t.Errorf("expected error %v for publisher %d, got %v", test.errs[i], i, ferr[i])
+					}
+				}
+			}
+
+			for i, tp := range tps {
+				if len(tp.Published) == 0 {
+					t.Errorf("expected publisher %d to receive messages", i)
+				}
+			}
+		})
+	}
+}
+
+func TestFanOutPublisherSingle(t *testing.T) {
+	first, second := &pubsubtest.TestPublisher{}, &pubsubtest.TestPublisher{}
+	fan := pubsub.NewFanOutPublisher(pubsub.FanOutAll, first, second)
+
+	if err := fan.PublishRaw(context.Background(), "key", []byte("msg")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, tp := range []*pubsubtest.TestPublisher{first, second} {
+		if len(tp.Published) != 1 || tp.Published[0].Key != "key" || string(tp.Published[0].Body) != "msg" {
+			t.Errorf("unexpected published messages: %#v", tp.Published)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package file
+
+import (
+	"github.com/kelseyhightower/envconfig"
+)
+
+// Config holds the information required to record messages to and replay
+// messages from a local file.
+type Config struct {
+	// Path is the path of the JSONL file records are appended to by the
+	// Publisher and read from by the Subscriber.
+	Path string `envconfig:"PUBSUB_FILE_PATH"`
+
+	// Speed controls the timing of the Subscriber's replay. A value of 1 will
+	// replay messages with their original timing, 2 will replay them twice as
+	// fast and so on. If 0, messages are replayed as fast as they are consumed.
+	Speed float64 `envconfig:"PUBSUB_FILE_REPLAY_SPEED"`
+}
+
+// LoadConfigFromEnv will attempt to load a file config
+// from environment variables.
+func LoadConfigFromEnv() Config {
+	var cfg Config
+	envconfig.Process("", &cfg)
+	return cfg
+}
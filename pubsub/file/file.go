@@ -0,0 +1,288 @@
+package file // import "github.com/NYTimes/gizmo/pubsub/file"
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/NYTimes/gizmo/observe"
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+// Record is a single message as it is stored on disk, one JSON object per line.
+type Record struct {
+	// Time is when the message was recorded. It is used to reproduce the
+	// original timing on replay.
+	Time       time.Time         `json:"time"`
+	Key        string            `json:"key,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Body       []byte            `json:"body"`
+}
+
+type key int
+
+// attrsKey is the context key for the attributes recorded with published messages.
+const attrsKey key = 0
+
+// WithAttributes will add attributes to the context that are recorded with
+// messages published with it.
+func WithAttributes(ctx context.Context, attrs map[string]string) context.Context {
+	return context.WithValue(ctx, attrsKey, attrs)
+}
+
+// Publisher appends every published message to a JSONL file so it can be
+// replayed later with a Subscriber.
+type Publisher struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+var _ pubsub.Publisher = &Publisher{}
+var _ pubsub.MultiPublisher = &Publisher{}
+
+// NewPublisher will open, or create, the file at the configured path for
+// appending records.
+func NewPublisher(cfg Config) (*Publisher, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("file path is required")
+	}
+	f, err := os.OpenFile(cfg.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Publisher{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Publish will marshal the proto message and record it.
+func (p *Publisher) Publish(ctx context.Context, key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(ctx, key, mb)
+}
+
+// PublishRaw will record the byte array with the current time and any
+// attributes set on the context with WithAttributes. The request ID of the
+// context, if any, is recorded as the x-request-id attribute.
+func (p *Publisher) PublishRaw(ctx context.Context, key string, m []byte) error {
+	var attrs map[string]string
+	if ctx != nil {
+		attrs, _ = ctx.Value(attrsKey).(map[string]string)
+	}
+	if id := observe.RequestID(ctx); id != "" {
+		if _, ok := attrs[observe.RequestIDKey]; !ok {
+			withID := make(map[string]string, len(attrs)+1)
+			for k, v := range attrs {
+				withID[k] = v
+			}
+			withID[observe.RequestIDKey] = id
+			attrs = withID
+		}
+	}
+	return p.WriteRecord(Record{Time: time.Now(), Key: key, Attributes: attrs, Body: m})
+}
+
+// PublishMulti will marshal and record multiple messages.
+func (p *Publisher) PublishMulti(ctx context.Context, keys []string, messages []proto.Message) error {
+	if len(keys) != len(messages) {
+		return errors.New("keys and messages must be equal length")
+	}
+
+	a := make([][]byte, len(messages))
+	for i := range messages {
+		b, err := proto.Marshal(messages[i])
+		if err != nil {
+			return err
+		}
+		a[i] = b
+	}
+	return p.PublishMultiRaw(ctx, keys, a)
+}
+
+// PublishMultiRaw will record multiple raw byte array messages.
+func (p *Publisher) PublishMultiRaw(ctx context.Context, keys []string, messages [][]byte) error {
+	if len(keys) != len(messages) {
+		return errors.New("keys and messages must be equal length")
+	}
+
+	for i := range messages {
+		if err := p.PublishRaw(ctx, keys[i], messages[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteRecord will append the given Record to the file. This can be used to
+// capture messages along with any attributes they were received with, for
+// example when teeing messages from another pubsub.Subscriber.
+func (p *Publisher) WriteRecord(r Record) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.enc.Encode(r)
+}
+
+// Stop will close the underlying file.
+func (p *Publisher) Stop() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.f.Close()
+}
+
+// Subscriber replays the records of a file written by a Publisher via the
+// pubsub.Subscriber interface. Once every record has been emitted, the
+// returned channel is closed and Err() will return nil.
+type Subscriber struct {
+	f     *os.File
+	speed float64
+
+	mu   sync.Mutex
+	stop chan chan error
+	done chan struct{}
+
+	err error
+}
+
+// NewSubscriber will open the file at the configured path for replay.
+func NewSubscriber(cfg Config) (*Subscriber, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("file path is required")
+	}
+	if cfg.Speed < 0 {
+		return nil, errors.New("replay speed cannot be negative")
+	}
+	f, err := os.Open(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &Subscriber{
+		f:     f,
+		speed: cfg.Speed,
+		stop:  make(chan chan error),
+	}, nil
+}
+
+// Start will start replaying the file and emit each record to the
+// returned channel. If it encounters an unreadable record, it will populate
+// the Err() error and close the returned channel.
+func (s *Subscriber) Start() <-chan pubsub.SubscriberMessage {
+	output := make(chan pubsub.SubscriberMessage)
+	s.mu.Lock()
+	s.done = make(chan struct{})
+	s.mu.Unlock()
+
+	go func(s *Subscriber, output chan pubsub.SubscriberMessage) {
+		defer close(s.done)
+		defer close(output)
+
+		var (
+			first time.Time
+			start = time.Now()
+			r     = bufio.NewReader(s.f)
+		)
+		for {
+			line, err := r.ReadBytes('\n')
+			if err == io.EOF && len(line) == 0 {
+				return
+			}
+			if err != nil && err != io.EOF {
+				s.err = err
+				return
+			}
+
+			var rec Record
+			if err = json.Unmarshal(line, &rec); err != nil {
+				s.err = err
+				return
+			}
+
+			var wait <-chan time.Time
+			if s.speed > 0 {
+				if first.IsZero() {
+					first = rec.Time
+				}
+				offset := time.Duration(float64(rec.Time.Sub(first)) / s.speed)
+				wait = time.After(time.Until(start.Add(offset)))
+			}
+			if wait != nil {
+				select {
+				case <-wait:
+				case exit := <-s.stop:
+					exit <- s.f.Close()
+					return
+				}
+			}
+
+			select {
+			case output <- &SubMessage{
+				Time:       rec.Time,
+				Key:        rec.Key,
+				Attributes: rec.Attributes,
+				body:       rec.Body,
+			}:
+			case exit := <-s.stop:
+				exit <- s.f.Close()
+				return
+			}
+		}
+	}(s, output)
+	return output
+}
+
+// Err will contain any error the Subscriber has encountered while replaying.
+func (s *Subscriber) Err() error {
+	return s.err
+}
+
+// Stop will block until the replay has stopped and close the underlying file.
+func (s *Subscriber) Stop() error {
+	s.mu.Lock()
+	done := s.done
+	s.mu.Unlock()
+	if done == nil {
+		return s.f.Close()
+	}
+
+	exit := make(chan error)
+	select {
+	case s.stop <- exit:
+		return <-exit
+	case <-done:
+		return s.f.Close()
+	}
+}
+
+// SubMessage is a replayed Record implementing pubsub.SubscriberMessage.
+type SubMessage struct {
+	body []byte
+
+	// Time is when the message was originally recorded.
+	Time time.Time
+	// Key is the key the message was published with.
+	Key string
+	// Attributes contains any attributes recorded with the message.
+	Attributes map[string]string
+}
+
+// Message will return the body of the recorded message.
+func (m *SubMessage) Message() []byte {
+	return m.body
+}
+
+// ExtendDoneDeadline has no effect on SubMessage.
+func (m *SubMessage) ExtendDoneDeadline(time.Duration) error {
+	return nil
+}
+
+// Done has no effect on SubMessage.
+func (m *SubMessage) Done() error {
+	return nil
+}
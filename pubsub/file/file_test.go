@@ -0,0 +1,171 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/observe"
+	"golang.org/x/net/context"
+)
+
+func tempPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "gizmo-pubsub-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "messages.jsonl"), func() { os.RemoveAll(dir) }
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	pub, err := NewPublisher(Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithAttributes(observe.WithRequestID(context.Background(), "abc-123"),
+		map[string]string{"source": "publish"})
+	err = pub.PublishMultiRaw(ctx,
+		[]string{"key-1", "key-2"}, [][]byte{[]byte("one"), []byte("two")})
+	if err != nil {
+		t.Fatalf("unexpected error publishing: %s", err)
+	}
+	err = pub.WriteRecord(Record{
+		Time:       time.Now(),
+		Key:        "key-3",
+		Attributes: map[string]string{"source": "test"},
+		Body:       []byte{0, 1, 2},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error writing record: %s", err)
+	}
+	if err = pub.Stop(); err != nil {
+		t.Fatalf("unexpected error stopping publisher: %s", err)
+	}
+
+	sub, err := NewSubscriber(Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []*SubMessage
+	for msg := range sub.Start() {
+		got = append(got, msg.(*SubMessage))
+		if err := msg.Done(); err != nil {
+			t.Errorf("unexpected error on Done: %s", err)
+		}
+	}
+	if err := sub.Err(); err != nil {
+		t.Fatalf("unexpected subscriber error: %s", err)
+	}
+	if err := sub.Stop(); err != nil {
+		t.Errorf("unexpected error stopping subscriber: %s", err)
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(got))
+	}
+	wantBodies := [][]byte{[]byte("one"), []byte("two"), {0, 1, 2}}
+	wantKeys := []string{"key-1", "key-2", "key-3"}
+	for i := range got {
+		if !reflect.DeepEqual(got[i].Message(), wantBodies[i]) {
+			t.Errorf("expected body %q, got %q", wantBodies[i], got[i].Message())
+		}
+		if got[i].Key != wantKeys[i] {
+			t.Errorf("expected key %q, got %q", wantKeys[i], got[i].Key)
+		}
+	}
+	for i, want := range []string{"publish", "publish", "test"} {
+		if got[i].Attributes["source"] != want {
+			t.Errorf("expected recorded attributes of %q, got %#v", want, got[i].Attributes)
+		}
+	}
+	if got[0].Attributes[observe.RequestIDKey] != "abc-123" {
+		t.Errorf("expected the request ID to be recorded, got %#v", got[0].Attributes)
+	}
+}
+
+func TestReplayTiming(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	pub, err := NewPublisher(Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	pub.WriteRecord(Record{Time: now, Body: []byte("one")})
+	pub.WriteRecord(Record{Time: now.Add(400 * time.Millisecond), Body: []byte("two")})
+	pub.Stop()
+
+	sub, err := NewSubscriber(Config{Path: path, Speed: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Stop()
+
+	start := time.Now()
+	var count int
+	for range sub.Start() {
+		count++
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 messages, got %d", count)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected replay to take at least 100ms at 4x speed, took %s", elapsed)
+	}
+}
+
+func TestReplayStop(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	pub, err := NewPublisher(Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	pub.WriteRecord(Record{Time: now, Body: []byte("one")})
+	pub.WriteRecord(Record{Time: now.Add(time.Hour), Body: []byte("two")})
+	pub.Stop()
+
+	sub, err := NewSubscriber(Config{Path: path, Speed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := sub.Start()
+	if msg := <-msgs; string(msg.Message()) != "one" {
+		t.Errorf("expected first message %q, got %q", "one", msg.Message())
+	}
+	if err := sub.Stop(); err != nil {
+		t.Errorf("unexpected error stopping: %s", err)
+	}
+	if _, ok := <-msgs; ok {
+		t.Error("expected channel to be closed after Stop")
+	}
+}
+
+func TestReplayBadRecord(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	if err := ioutil.WriteFile(path, []byte("not json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := NewSubscriber(Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-sub.Start(); ok {
+		t.Fatal("expected channel to be closed")
+	}
+	if sub.Err() == nil {
+		t.Error("expected an error for an invalid record")
+	}
+}
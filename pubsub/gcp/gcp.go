@@ -6,6 +6,7 @@ import (
 	"time"
 
 	gpubsub "cloud.google.com/go/pubsub"
+	"github.com/NYTimes/gizmo/observe"
 	"github.com/NYTimes/gizmo/pubsub"
 	"github.com/golang/protobuf/proto"
 	"golang.org/x/net/context"
@@ -174,11 +175,12 @@ func (p *publisher) Publish(ctx context.Context, key string, msg proto.Message)
 	return p.PublishRaw(ctx, key, mb)
 }
 
-// PublishRaw will publish the message to GCP pubsub.
+// PublishRaw will publish the message to GCP pubsub. The request ID of the
+// context, if any, is added to the message attributes.
 func (p *publisher) PublishRaw(ctx context.Context, key string, m []byte) error {
 	res := p.topic.Publish(ctx, &gpubsub.Message{
 		Data:       m,
-		Attributes: map[string]string{"key": key},
+		Attributes: attributes(ctx, key),
 	})
 	_, err := res.Get(ctx)
 	return err
@@ -217,6 +219,15 @@ func (p *publisher) PublishMultiRaw(ctx context.Context, keys []string, messages
 	return nil
 }
 
+// attributes returns the attributes of a published message.
+func attributes(ctx context.Context, key string) map[string]string {
+	attrs := map[string]string{"key": key}
+	if id := observe.RequestID(ctx); id != "" {
+		attrs[observe.RequestIDKey] = id
+	}
+	return attrs
+}
+
 // interfaces and types to make this more testable
 type (
 	subscription interface {
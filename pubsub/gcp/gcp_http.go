@@ -45,13 +45,14 @@ func (p *httpPublisher) Publish(ctx context.Context, key string, msg proto.Messa
 	return p.PublishRaw(ctx, key, mb)
 }
 
-// PublishRaw will publish the message to GCP pubsub.
+// PublishRaw will publish the message to GCP pubsub. The request ID of the
+// context, if any, is added to the message attributes.
 func (p *httpPublisher) PublishRaw(ctx context.Context, key string, m []byte) error {
 	call := p.svc.Publish(p.topic, &v1pubsub.PublishRequest{
 		Messages: []*v1pubsub.PubsubMessage{
 			{
 				Data:       base64.StdEncoding.EncodeToString(m),
-				Attributes: map[string]string{"key": key},
+				Attributes: attributes(ctx, key),
 			},
 		},
 	})
@@ -86,7 +87,7 @@ func (p *httpPublisher) PublishMultiRaw(ctx context.Context, keys []string, mess
 	for i := range messages {
 		a[i] = &v1pubsub.PubsubMessage{
 			Data:       base64.StdEncoding.EncodeToString(messages[i]),
-			Attributes: map[string]string{"key": keys[i]},
+			Attributes: attributes(ctx, keys[i]),
 		}
 	}
 
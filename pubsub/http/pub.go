@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/NYTimes/gizmo/observe"
 	"github.com/NYTimes/gizmo/pubsub"
 	"github.com/golang/protobuf/proto"
 	"golang.org/x/net/context"
@@ -80,12 +81,15 @@ func (p Publisher) PublishMultiRaw(ctx context.Context, _ []string, msgs [][]byt
 }
 
 // PublishRaw will POST the given message payload at the URL provided in the Publisher
-// construct.
-func (p Publisher) PublishRaw(_ context.Context, _ string, payload []byte) error {
+// construct. The request ID of the context, if any, is sent in the X-Request-Id header.
+func (p Publisher) PublishRaw(ctx context.Context, _ string, payload []byte) error {
 	req, err := http.NewRequest("POST", p.url, bytes.NewReader(payload))
 	if err != nil {
 		return err
 	}
+	if id := observe.RequestID(ctx); id != "" {
+		req.Header.Set(observe.RequestIDKey, id)
+	}
 
 	resp, err := p.client.Do(req)
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/NYTimes/gizmo/observe"
 	"github.com/NYTimes/gizmo/pubsub"
 
 	"github.com/Shopify/sarama"
@@ -23,6 +24,8 @@ var (
 type Publisher struct {
 	producer sarama.SyncProducer
 	topic    string
+	// headers is set if the producer's Kafka version supports record headers.
+	headers bool
 }
 
 // NewPublisher will initiate a new experimental Kafka publisher.
@@ -43,6 +46,7 @@ func NewPublisher(cfg *Config) (pubsub.Publisher, error) {
 	}
 	// we always want successes to return
 	sconfig.Producer.Return.Successes = true
+	p.headers = sconfig.Version.IsAtLeast(sarama.V0_11_0_0)
 	p.producer, err = sarama.NewSyncProducer(cfg.BrokerHosts, sconfig)
 	return p, err
 }
@@ -57,12 +61,20 @@ func (p *Publisher) Publish(ctx context.Context, key string, m proto.Message) er
 }
 
 // PublishRaw will emit the byte array to the Kafka topic.
-func (p *Publisher) PublishRaw(_ context.Context, key string, m []byte) error {
+// If the producer's Config.Version is at least 0.11, the request ID of the
+// context, if any, is added as the x-request-id record header.
+func (p *Publisher) PublishRaw(ctx context.Context, key string, m []byte) error {
 	msg := &sarama.ProducerMessage{
 		Topic: p.topic,
 		Key:   sarama.StringEncoder(key),
 		Value: sarama.ByteEncoder(m),
 	}
+	if id := observe.RequestID(ctx); id != "" && p.headers {
+		msg.Headers = []sarama.RecordHeader{{
+			Key:   []byte(observe.RequestIDKey),
+			Value: []byte(id),
+		}}
+	}
 	// TODO: do something with this partition/offset values
 	_, _, err := p.producer.SendMessage(msg)
 	return err
@@ -0,0 +1,122 @@
+package pubsub
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// MergeError is returned by the Err and Stop methods of a merged Subscriber.
+// It holds an entry for every underlying subscriber, in the order they were
+// given to MergeSubscribers, with nil entries for the ones without an error.
+type MergeError []error
+
+// Error will list the errors of each failed subscriber.
+func (e MergeError) Error() string {
+	var msgs []string
+	for i, err := range e {
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("subscriber %d: %s", i, err))
+		}
+	}
+	return "merged subscriber failed: " + strings.Join(msgs, "; ")
+}
+
+// MergedMessage is emitted by a merged Subscriber. It wraps the original
+// message and tags it with the index of the subscriber it came from.
+type MergedMessage struct {
+	SubscriberMessage
+
+	// Source is the index of the subscriber, as given to MergeSubscribers,
+	// that emitted the message.
+	Source int
+}
+
+type mergedSubscriber struct {
+	subs []Subscriber
+
+	stopped  chan struct{}
+	stopOnce sync.Once
+	stopErr  error
+}
+
+// MergeSubscribers will return a Subscriber that multiplexes the messages of
+// all the given subscribers into a single channel. Each message is emitted as
+// a *MergedMessage so consumers can tell which subscriber it came from.
+//
+// The returned channel is closed once every underlying subscriber's channel
+// has closed. If any subscriber closes its channel with an error, the rest
+// are stopped and Err() will report which subscriber failed. Stop will stop
+// every underlying subscriber.
+func MergeSubscribers(subs ...Subscriber) Subscriber {
+	return &mergedSubscriber{
+		subs:    subs,
+		stopped: make(chan struct{}),
+	}
+}
+
+// Start will start every underlying subscriber and emit their messages to
+// the returned channel.
+func (m *mergedSubscriber) Start() <-chan SubscriberMessage {
+	output := make(chan SubscriberMessage)
+
+	var wg sync.WaitGroup
+	for i, s := range m.subs {
+		wg.Add(1)
+		go func(i int, s Subscriber, in <-chan SubscriberMessage) {
+			defer wg.Done()
+			for msg := range in {
+				// once stopped, keep draining so the subscriber can shut down.
+				select {
+				case output <- &MergedMessage{SubscriberMessage: msg, Source: i}:
+				case <-m.stopped:
+				}
+			}
+			if s.Err() != nil {
+				go m.Stop()
+			}
+		}(i, s, s.Start())
+	}
+
+	go func() {
+		wg.Wait()
+		close(output)
+	}()
+	return output
+}
+
+// Err will return a MergeError if any of the underlying subscribers
+// has encountered an error.
+func (m *mergedSubscriber) Err() error {
+	errs := make(MergeError, len(m.subs))
+	var failed bool
+	for i, s := range m.subs {
+		if errs[i] = s.Err(); errs[i] != nil {
+			failed = true
+		}
+	}
+	if !failed {
+		return nil
+	}
+	return errs
+}
+
+// Stop will stop every underlying subscriber and return a MergeError if
+// any of them failed to stop.
+func (m *mergedSubscriber) Stop() error {
+	m.stopOnce.Do(func() {
+		close(m.stopped)
+
+		errs := make(MergeError, len(m.subs))
+		var failed bool
+		for i, s := range m.subs {
+			if errs[i] = s.Stop(); errs[i] != nil {
+				failed = true
+			}
+		}
+		if failed {
+			m.stopErr = errs
+		}
+	})
+	return m.stopErr
+}
@@ -0,0 +1,153 @@
+package pubsub_test
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/pubsub/pubsubtest"
+)
+
+func TestMergeSubscribers(t *testing.T) {
+	first := &pubsubtest.TestSubscriber{JSONMessages: []interface{}{"a", "b"}}
+	second := &pubsubtest.TestSubscriber{JSONMessages: []interface{}{"c"}}
+	sub := pubsub.MergeSubscribers(first, second)
+
+	var got []string
+	for msg := range sub.Start() {
+		mm, ok := msg.(*pubsub.MergedMessage)
+		if !ok {
+			t.Fatalf("expected a *MergedMessage, got %T", msg)
+		}
+		if err := mm.Done(); err != nil {
+			t.Errorf("unexpected error on Done: %s", err)
+		}
+		got = append(got, fmt.Sprintf("%d:%s", mm.Source, mm.Message()))
+	}
+	sort.Strings(got)
+
+	want := []string{`0:"a"`, `0:"b"`, `1:"c"`}
+	if len(got) != len(want) {
+		t.Fatalf("expected messages %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected message %s, got %s", want[i], got[i])
+		}
+	}
+	if err := sub.Err(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := sub.Stop(); err != nil {
+		t.Errorf("unexpected error stopping: %s", err)
+	}
+}
+
+func TestMergeSubscribersError(t *testing.T) {
+	wantErr := errors.New("sqs failed")
+	blocking := newBlockingSubscriber()
+	failing := &pubsubtest.TestSubscriber{GivenErrError: wantErr}
+	sub := pubsub.MergeSubscribers(blocking, failing)
+
+	select {
+	case _, ok := <-drain(sub.Start()):
+		if ok {
+			t.Fatal("expected channel to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected failing subscriber to stop the merged subscriber")
+	}
+
+	err, ok := sub.Err().(pubsub.MergeError)
+	if !ok {
+		t.Fatalf("expected a MergeError, got %T", sub.Err())
+	}
+	if err[0] != nil || err[1] != wantErr {
+		t.Errorf("expected only subscriber 1 to fail, got %v", err)
+	}
+	if !blocking.isStopped() {
+		t.Error("expected the other subscriber to be stopped")
+	}
+}
+
+func TestMergeSubscribersStop(t *testing.T) {
+	wantErr := errors.New("stop failed")
+	blocking := newBlockingSubscriber()
+	failing := &pubsubtest.TestSubscriber{GivenStopError: wantErr}
+	sub := pubsub.MergeSubscribers(blocking, failing)
+
+	msgs := sub.Start()
+	blocking.msgs <- &pubsubtest.TestSubsMessage{Msg: []byte("one")}
+	if msg := <-msgs; msg.(*pubsub.MergedMessage).Source != 0 {
+		t.Errorf("expected message from subscriber 0, got %d", msg.(*pubsub.MergedMessage).Source)
+	}
+
+	err, ok := sub.Stop().(pubsub.MergeError)
+	if !ok {
+		t.Fatalf("expected a MergeError, got %T", sub.Stop())
+	}
+	if err[0] != nil || err[1] != wantErr {
+		t.Errorf("expected only subscriber 1 to fail to stop, got %v", err)
+	}
+	if _, ok := <-drain(msgs); ok {
+		t.Error("expected channel to be closed after Stop")
+	}
+}
+
+// drain will discard messages until the given channel is closed.
+func drain(msgs <-chan pubsub.SubscriberMessage) <-chan pubsub.SubscriberMessage {
+	out := make(chan pubsub.SubscriberMessage)
+	go func() {
+		for range msgs {
+		}
+		close(out)
+	}()
+	return out
+}
+
+type blockingSubscriber struct {
+	msgs    chan pubsub.SubscriberMessage
+	stopped chan struct{}
+}
+
+func newBlockingSubscriber() *blockingSubscriber {
+	return &blockingSubscriber{
+		msgs:    make(chan pubsub.SubscriberMessage),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (b *blockingSubscriber) Start() <-chan pubsub.SubscriberMessage {
+	out := make(chan pubsub.SubscriberMessage)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case msg := <-b.msgs:
+				out <- msg
+			case <-b.stopped:
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (b *blockingSubscriber) Err() error { return nil }
+
+func (b *blockingSubscriber) Stop() error {
+	close(b.stopped)
+	return nil
+}
+
+func (b *blockingSubscriber) isStopped() bool {
+	select {
+	case <-b.stopped:
+		return true
+	default:
+		return false
+	}
+}
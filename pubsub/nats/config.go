@@ -0,0 +1,67 @@
+package nats
+
+import (
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	natsio "github.com/nats-io/nats.go"
+)
+
+var (
+	// DefaultBatchSize is the default number of messages the subscriber will
+	// fetch from JetStream in a single pull request.
+	DefaultBatchSize = 10
+	// DefaultFetchTimeout is the default amount of time the subscriber will
+	// wait for a pull request to return messages.
+	DefaultFetchTimeout = 5 * time.Second
+)
+
+// Config holds the basic information for working with NATS and JetStream.
+type Config struct {
+	// URL is a comma separated list of NATS server URLs.
+	// If empty, natsio.DefaultURL will be used.
+	URL string `envconfig:"NATS_URL"`
+
+	// Subject is the subject messages will be published to. For the JetStream
+	// subscriber, it is used as the filter subject of the durable consumer.
+	Subject string `envconfig:"NATS_SUBJECT"`
+
+	// Stream is the name of the JetStream stream the subscriber will bind to.
+	// If empty, the stream will be looked up via the Subject.
+	Stream string `envconfig:"NATS_STREAM"`
+	// Durable is the name of the durable pull consumer. It is required for
+	// subscribing.
+	Durable string `envconfig:"NATS_DURABLE"`
+
+	// BatchSize will override the DefaultBatchSize.
+	BatchSize int `envconfig:"NATS_BATCH_SIZE"`
+	// FetchTimeout will override the DefaultFetchTimeout.
+	FetchTimeout time.Duration `envconfig:"NATS_FETCH_TIMEOUT"`
+	// AckWait is the amount of time JetStream will wait for a message to be
+	// acknowledged before redelivering it. If empty, the server default is used.
+	AckWait time.Duration `envconfig:"NATS_ACK_WAIT"`
+
+	// Options are passed along to the NATS client for more control over the
+	// underlying connection.
+	Options []natsio.Option `ignored:"true"`
+}
+
+// LoadConfigFromEnv will attempt to load a NATS config
+// from environment variables.
+func LoadConfigFromEnv() Config {
+	var cfg Config
+	envconfig.Process("", &cfg)
+	return cfg
+}
+
+func defaultConfig(cfg *Config) {
+	if cfg.URL == "" {
+		cfg.URL = natsio.DefaultURL
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.FetchTimeout <= 0 {
+		cfg.FetchTimeout = DefaultFetchTimeout
+	}
+}
@@ -0,0 +1,246 @@
+package nats // import "github.com/NYTimes/gizmo/pubsub/nats"
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/NYTimes/gizmo/observe"
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/golang/protobuf/proto"
+	natsio "github.com/nats-io/nats.go"
+	"golang.org/x/net/context"
+)
+
+// KeyHeader is the message header the publish key is stored in.
+const KeyHeader = "key"
+
+// Publisher is a publisher that emits messages to a subject over core NATS.
+type Publisher struct {
+	conn    *natsio.Conn
+	subject string
+}
+
+var _ pubsub.Publisher = &Publisher{}
+var _ pubsub.MultiPublisher = &Publisher{}
+
+// NewPublisher will connect to NATS and return a new Publisher.
+func NewPublisher(cfg Config) (*Publisher, error) {
+	if cfg.Subject == "" {
+		return nil, errors.New("subject is required")
+	}
+	defaultConfig(&cfg)
+
+	conn, err := natsio.Connect(cfg.URL, cfg.Options...)
+	if err != nil {
+		return nil, err
+	}
+	return &Publisher{conn: conn, subject: cfg.Subject}, nil
+}
+
+// Publish will marshal the proto message and publish it to the NATS subject.
+func (p *Publisher) Publish(ctx context.Context, key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(ctx, key, mb)
+}
+
+// PublishRaw will publish the byte array to the NATS subject. The key will be
+// set in the KeyHeader of the message and the request ID of the context, if
+// any, in the x-request-id header.
+func (p *Publisher) PublishRaw(ctx context.Context, key string, m []byte) error {
+	msg := natsio.NewMsg(p.subject)
+	msg.Header.Set(KeyHeader, key)
+	if id := observe.RequestID(ctx); id != "" {
+		msg.Header.Set(observe.RequestIDKey, id)
+	}
+	msg.Data = m
+	return p.conn.PublishMsg(msg)
+}
+
+// PublishMulti will publish multiple messages to the NATS subject.
+func (p *Publisher) PublishMulti(ctx context.Context, keys []string, messages []proto.Message) error {
+	if len(keys) != len(messages) {
+		return errors.New("keys and messages must be equal length")
+	}
+
+	a := make([][]byte, len(messages))
+	for i := range messages {
+		b, err := proto.Marshal(messages[i])
+		if err != nil {
+			return err
+		}
+		a[i] = b
+	}
+	return p.PublishMultiRaw(ctx, keys, a)
+}
+
+// PublishMultiRaw will publish multiple raw byte array messages to the NATS
+// subject and flush the connection.
+func (p *Publisher) PublishMultiRaw(ctx context.Context, keys []string, messages [][]byte) error {
+	if len(keys) != len(messages) {
+		return errors.New("keys and messages must be equal length")
+	}
+
+	for i := range messages {
+		if err := p.PublishRaw(ctx, keys[i], messages[i]); err != nil {
+			return err
+		}
+	}
+	return p.conn.Flush()
+}
+
+// Stop will flush any pending messages and close the NATS connection.
+func (p *Publisher) Stop() error {
+	err := p.conn.Flush()
+	p.conn.Close()
+	return err
+}
+
+// Subscriber is a JetStream durable pull consumer that allows a user to
+// consume messages via the pubsub.Subscriber interface.
+type Subscriber struct {
+	conn *natsio.Conn
+	sub  *natsio.Subscription
+	cfg  Config
+
+	ctx    context.Context
+	cancel func()
+	done   chan struct{}
+
+	mtxStop sync.Mutex
+	started bool
+	stopped bool
+
+	err error
+}
+
+// NewSubscriber will connect to NATS and create or bind to the durable JetStream
+// pull consumer described by the Config.
+func NewSubscriber(cfg Config) (*Subscriber, error) {
+	if cfg.Subject == "" {
+		return nil, errors.New("subject is required")
+	}
+	if cfg.Durable == "" {
+		return nil, errors.New("durable name is required")
+	}
+	defaultConfig(&cfg)
+
+	conn, err := natsio.Connect(cfg.URL, cfg.Options...)
+	if err != nil {
+		return nil, err
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	var opts []natsio.SubOpt
+	if cfg.Stream != "" {
+		opts = append(opts, natsio.BindStream(cfg.Stream))
+	}
+	if cfg.AckWait > 0 {
+		opts = append(opts, natsio.AckWait(cfg.AckWait))
+	}
+	sub, err := js.PullSubscribe(cfg.Subject, cfg.Durable, opts...)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Subscriber{
+		conn:   conn,
+		sub:    sub,
+		cfg:    cfg,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Start will start fetching batches of messages from the JetStream consumer
+// and emit them to the returned channel. If it encounters any issues, it will
+// populate the Err() error and close the returned channel.
+func (s *Subscriber) Start() <-chan pubsub.SubscriberMessage {
+	s.mtxStop.Lock()
+	s.started = true
+	s.mtxStop.Unlock()
+
+	output := make(chan pubsub.SubscriberMessage)
+	go func(s *Subscriber, output chan pubsub.SubscriberMessage) {
+		defer close(s.done)
+		defer close(output)
+		for {
+			ctx, cancel := context.WithTimeout(s.ctx, s.cfg.FetchTimeout)
+			msgs, err := s.sub.Fetch(s.cfg.BatchSize, natsio.Context(ctx))
+			cancel()
+			if s.ctx.Err() != nil {
+				return
+			}
+			if err != nil && err != natsio.ErrTimeout && err != context.DeadlineExceeded {
+				s.err = err
+				return
+			}
+			for _, msg := range msgs {
+				select {
+				case output <- &SubMessage{msg: msg, Header: msg.Header}:
+				case <-s.ctx.Done():
+					return
+				}
+			}
+		}
+	}(s, output)
+	return output
+}
+
+// Err will contain any error the Subscriber has encountered while processing.
+func (s *Subscriber) Err() error {
+	return s.err
+}
+
+// Stop will block until the consumer has stopped fetching messages and close
+// the NATS connection. The durable consumer is left in place so a later
+// Subscriber can resume where this one left off.
+func (s *Subscriber) Stop() error {
+	s.mtxStop.Lock()
+	defer s.mtxStop.Unlock()
+	if s.stopped {
+		return nil
+	}
+	s.stopped = true
+	s.cancel()
+	if s.started {
+		<-s.done
+	}
+	s.conn.Close()
+	return nil
+}
+
+// SubMessage is the NATS JetStream implementation of pubsub.SubscriberMessage.
+type SubMessage struct {
+	msg *natsio.Msg
+	// Header contains the headers of the message. The publish key, if any,
+	// can be found under the KeyHeader.
+	Header natsio.Header
+}
+
+// Message will return the data of the NATS message.
+func (m *SubMessage) Message() []byte {
+	return m.msg.Data
+}
+
+// ExtendDoneDeadline will send an InProgress acknowledgement to JetStream,
+// which resets the redelivery timer to the consumer's AckWait. The given
+// duration is ignored as JetStream does not support custom extensions.
+func (m *SubMessage) ExtendDoneDeadline(time.Duration) error {
+	return m.msg.InProgress()
+}
+
+// Done will acknowledge the message with JetStream.
+func (m *SubMessage) Done() error {
+	return m.msg.Ack()
+}
@@ -0,0 +1,197 @@
+package nats
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/observe"
+	"github.com/nats-io/nats-server/v2/server"
+	natsio "github.com/nats-io/nats.go"
+	"golang.org/x/net/context"
+)
+
+func runServer(t *testing.T) (*server.Server, func()) {
+	dir, err := ioutil.TempDir("", "gizmo-nats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server was not ready for connections")
+	}
+	return srv, func() {
+		srv.Shutdown()
+		os.RemoveAll(dir)
+	}
+}
+
+func addStream(t *testing.T, url, name string, subjects ...string) {
+	conn, err := natsio.Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	js, err := conn.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = js.AddStream(&natsio.StreamConfig{Name: name, Subjects: subjects})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	srv, shutdown := runServer(t)
+	defer shutdown()
+
+	conn, err := natsio.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	core, err := conn.SubscribeSync("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := NewPublisher(Config{URL: srv.ClientURL(), Subject: "events"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Stop()
+
+	ctx := observe.WithRequestID(context.Background(), "abc-123")
+	err = pub.PublishMultiRaw(ctx,
+		[]string{"key-1", "key-2"}, [][]byte{[]byte("one"), []byte("two")})
+	if err != nil {
+		t.Fatalf("unexpected error publishing: %s", err)
+	}
+
+	keys := []string{"key-1", "key-2"}
+	for i, want := range []string{"one", "two"} {
+		msg, err := core.NextMsg(time.Second)
+		if err != nil {
+			t.Fatalf("expected message %d: %s", i, err)
+		}
+		if got := string(msg.Data); got != want {
+			t.Errorf("expected message %q, got %q", want, got)
+		}
+		if got := msg.Header.Get(KeyHeader); got != keys[i] {
+			t.Errorf("expected key %q, got %q", keys[i], got)
+		}
+		if got := msg.Header.Get(observe.RequestIDKey); got != "abc-123" {
+			t.Errorf("expected request ID header, got %q", got)
+		}
+	}
+
+	if err := pub.PublishMultiRaw(context.Background(), []string{"a"}, nil); err == nil {
+		t.Error("expected an error for mismatched keys and messages")
+	}
+}
+
+func TestSubscriber(t *testing.T) {
+	srv, shutdown := runServer(t)
+	defer shutdown()
+	addStream(t, srv.ClientURL(), "EVENTS", "events")
+
+	pub, err := NewPublisher(Config{URL: srv.ClientURL(), Subject: "events"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Stop()
+
+	cfg := Config{
+		URL:          srv.ClientURL(),
+		Subject:      "events",
+		Stream:       "EVENTS",
+		Durable:      "test",
+		BatchSize:    2,
+		FetchTimeout: 100 * time.Millisecond,
+		AckWait:      time.Second,
+	}
+	sub, err := NewSubscriber(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"one", "two", "three"}
+	for _, w := range want {
+		if err := pub.PublishRaw(context.Background(), "key-"+w, []byte(w)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msgs := sub.Start()
+	for _, w := range want {
+		select {
+		case msg := <-msgs:
+			if got := string(msg.Message()); got != w {
+				t.Errorf("expected message %q, got %q", w, got)
+			}
+			if got := msg.(*SubMessage).Header.Get(KeyHeader); got != "key-"+w {
+				t.Errorf("expected key %q, got %q", "key-"+w, got)
+			}
+			if err := msg.ExtendDoneDeadline(time.Minute); err != nil {
+				t.Errorf("unexpected error extending deadline: %s", err)
+			}
+			if err := msg.Done(); err != nil {
+				t.Errorf("unexpected error acking: %s", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for message %q", w)
+		}
+	}
+
+	if err := sub.Stop(); err != nil {
+		t.Errorf("unexpected error stopping: %s", err)
+	}
+	if _, ok := <-msgs; ok {
+		t.Error("expected channel to be closed after Stop")
+	}
+	if err := sub.Err(); err != nil {
+		t.Errorf("unexpected subscriber error: %s", err)
+	}
+
+	// a new subscriber on the same durable should resume after the acked messages
+	if err := pub.PublishRaw(context.Background(), "key-four", []byte("four")); err != nil {
+		t.Fatal(err)
+	}
+	sub, err = NewSubscriber(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Stop()
+	select {
+	case msg := <-sub.Start():
+		if got := string(msg.Message()); got != "four" {
+			t.Errorf("expected message %q, got %q", "four", got)
+		}
+		msg.Done()
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message after resuming")
+	}
+}
+
+func TestNewSubscriberMissingConfig(t *testing.T) {
+	if _, err := NewSubscriber(Config{Durable: "test"}); err == nil {
+		t.Error("expected an error without a subject")
+	}
+	if _, err := NewSubscriber(Config{Subject: "events"}); err == nil {
+		t.Error("expected an error without a durable name")
+	}
+	if _, err := NewPublisher(Config{}); err == nil {
+		t.Error("expected an error without a subject")
+	}
+}
@@ -0,0 +1,163 @@
+package pubsub
+
+import (
+	"errors"
+	"path"
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+// ErrNoRoute is returned by a RouterPublisher when no downstream publisher
+// matches a message's key.
+var ErrNoRoute = errors.New("no publisher found for key")
+
+// RouteFunc returns the Publisher a message with the given key should be
+// published to or nil if there is none.
+type RouteFunc func(key string) Publisher
+
+// Route pairs a key pattern with the Publisher that matching messages should
+// be published to. Patterns use the syntax of path.Match, so "users.*" will
+// match any key with the "users." prefix.
+type Route struct {
+	Pattern   string
+	Publisher Publisher
+}
+
+// RouterPublisher publishes each message to a single downstream publisher
+// chosen by the message's key.
+type RouterPublisher struct {
+	route RouteFunc
+}
+
+var _ MultiPublisher = &RouterPublisher{}
+
+// NewRouterPublisher will return a RouterPublisher that uses the given func to
+// pick a downstream publisher for each message.
+func NewRouterPublisher(route RouteFunc) *RouterPublisher {
+	return &RouterPublisher{route: route}
+}
+
+// NewPatternRouterPublisher will return a RouterPublisher that publishes each
+// message to the Publisher of the first Route whose Pattern matches its key.
+// If no Route matches, the message will be published to the fallback Publisher
+// or ErrNoRoute will be returned if fallback is nil.
+func NewPatternRouterPublisher(fallback Publisher, routes ...Route) (*RouterPublisher, error) {
+	for _, r := range routes {
+		if _, err := path.Match(r.Pattern, ""); err != nil {
+			return nil, err
+		}
+	}
+	return NewRouterPublisher(func(key string) Publisher {
+		for _, r := range routes {
+			if ok, _ := path.Match(r.Pattern, key); ok {
+				return r.Publisher
+			}
+		}
+		return fallback
+	}), nil
+}
+
+func (r *RouterPublisher) lookup(key string) (Publisher, error) {
+	p := r.route(key)
+	if p == nil {
+		return nil, ErrNoRoute
+	}
+	return p, nil
+}
+
+// Publish will publish the message to the publisher routed to by its key.
+func (r *RouterPublisher) Publish(ctx context.Context, key string, m proto.Message) error {
+	p, err := r.lookup(key)
+	if err != nil {
+		return err
+	}
+	return p.Publish(ctx, key, m)
+}
+
+// PublishRaw will publish the raw message to the publisher routed to by its key.
+func (r *RouterPublisher) PublishRaw(ctx context.Context, key string, m []byte) error {
+	p, err := r.lookup(key)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(ctx, key, m)
+}
+
+// PublishMulti will group the messages by their routed publisher and publish
+// each group, using PublishMulti on publishers that implement MultiPublisher.
+// No messages are published if any key fails to route.
+func (r *RouterPublisher) PublishMulti(ctx context.Context, keys []string, messages []proto.Message) error {
+	if len(keys) != len(messages) {
+		return errKeysMessagesLength
+	}
+	pubs, groups, err := r.group(keys)
+	if err != nil {
+		return err
+	}
+	for i, p := range pubs {
+		gkeys := make([]string, len(groups[i]))
+		gmsgs := make([]proto.Message, len(groups[i]))
+		for j, idx := range groups[i] {
+			gkeys[j], gmsgs[j] = keys[idx], messages[idx]
+		}
+		if err := publishMulti(ctx, p, gkeys, gmsgs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PublishMultiRaw will group the raw messages by their routed publisher and
+// publish each group, using PublishMultiRaw on publishers that implement
+// MultiPublisher. No messages are published if any key fails to route.
+func (r *RouterPublisher) PublishMultiRaw(ctx context.Context, keys []string, messages [][]byte) error {
+	if len(keys) != len(messages) {
+		return errKeysMessagesLength
+	}
+	pubs, groups, err := r.group(keys)
+	if err != nil {
+		return err
+	}
+	for i, p := range pubs {
+		gkeys := make([]string, len(groups[i]))
+		gmsgs := make([][]byte, len(groups[i]))
+		for j, idx := range groups[i] {
+			gkeys[j], gmsgs[j] = keys[idx], messages[idx]
+		}
+		if err := publishMultiRaw(ctx, p, gkeys, gmsgs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// group will return the distinct publishers for the given keys in the order
+// they were first seen along with the indexes of the keys routed to each.
+func (r *RouterPublisher) group(keys []string) ([]Publisher, [][]int, error) {
+	var (
+		pubs   []Publisher
+		groups [][]int
+	)
+	for i, key := range keys {
+		p, err := r.lookup(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		// publishers of uncomparable types can't be grouped.
+		found := false
+		for j := range pubs {
+			if reflect.TypeOf(p).Comparable() && pubs[j] == p {
+				groups[j] = append(groups[j], i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			pubs = append(pubs, p)
+			groups = append(groups, []int{i})
+		}
+	}
+	return pubs, groups, nil
+}
@@ -0,0 +1,77 @@
+package pubsub_test
+
+import (
+	"testing"
+
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/pubsub/pubsubtest"
+	"golang.org/x/net/context"
+)
+
+func TestPatternRouterPublisher(t *testing.T) {
+	users, orders, fallback := &pubsubtest.TestPublisher{}, &pubsubtest.TestPublisher{}, &pubsubtest.TestPublisher{}
+	router, err := pubsub.NewPatternRouterPublisher(fallback,
+		pubsub.Route{Pattern: "users.*", Publisher: users},
+		pubsub.Route{Pattern: "orders.*", Publisher: orders},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = router.PublishMultiRaw(context.Background(),
+		[]string{"users.created", "orders.placed", "users.deleted", "other"},
+		[][]byte{[]byte("1"), []byte("2"), []byte("3"), []byte("4")})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name string
+		pub  *pubsubtest.TestPublisher
+		keys []string
+	}{
+		{"users", users, []string{"users.created", "users.deleted"}},
+		{"orders", orders, []string{"orders.placed"}},
+		{"fallback", fallback, []string{"other"}},
+	}
+	for _, test := range tests {
+		if len(test.pub.Published) != len(test.keys) {
+			t.Errorf("%s: expected %d messages, got %d", test.name, len(test.keys), len(test.pub.Published))
+			continue
+		}
+		for i, key := range test.keys {
+			if got := test.pub.Published[i].Key; got != key {
+				t.Errorf("%s: expected key %q, got %q", test.name, key, got)
+			}
+		}
+	}
+
+	if _, err := pubsub.NewPatternRouterPublisher(nil, pubsub.Route{Pattern: "[", Publisher: users}); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
+
+func TestRouterPublisherNoRoute(t *testing.T) {
+	pub := &pubsubtest.TestPublisher{}
+	router := pubsub.NewRouterPublisher(func(key string) pubsub.Publisher {
+		if key == "known" {
+			return pub
+		}
+		return nil
+	})
+
+	if err := router.PublishRaw(context.Background(), "known", []byte("msg")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := router.PublishRaw(context.Background(), "unknown", []byte("msg")); err != pubsub.ErrNoRoute {
+		t.Errorf("expected ErrNoRoute, got %v", err)
+	}
+	err := router.PublishMultiRaw(context.Background(),
+		[]string{"known", "unknown"}, [][]byte{[]byte("1"), []byte("2")})
+	if err != pubsub.ErrNoRoute {
+		t.Errorf("expected ErrNoRoute, got %v", err)
+	}
+	if len(pub.Published) != 1 {
+		t.Errorf("expected no messages to be published on a failed route, got %d", len(pub.Published)-1)
+	}
+}
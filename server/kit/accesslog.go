@@ -0,0 +1,266 @@
+package kit
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/go-kit/kit/log/level"
+	"github.com/golang/protobuf/proto"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc/status"
+)
+
+// accessLogger writes structured access logs of HTTP and gRPC requests with the
+// request scoped logger.
+type accessLogger struct {
+	exclude    map[string]bool
+	sampleRate float64
+	// gcp will add the httpRequest field so Stackdriver renders request logs.
+	gcp  bool
+	rand func() float64
+}
+
+// newAccessLogger returns nil unless access logs are enabled.
+func newAccessLogger(cfg Config, gcp bool) *accessLogger {
+	if !cfg.AccessLog {
+		return nil
+	}
+	a := &accessLogger{
+		exclude:    make(map[string]bool, len(cfg.AccessLogExclude)),
+		sampleRate: cfg.AccessLogSampleRate,
+		gcp:        gcp,
+		rand:       rand.Float64,
+	}
+	for _, path := range cfg.AccessLogExclude {
+		a.exclude[path] = true
+	}
+	return a
+}
+
+// accessLogEntry collects the details of a request that are only known deeper in
+// the handler chain.
+type accessLogEntry struct {
+	route string
+}
+
+// withRoute will tag the request with the route template for metrics and access
+// logs.
+func withRoute(h http.Handler, route string) http.Handler {
+	return ochttp.WithRouteTag(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if e, ok := r.Context().Value(accessLogKey).(*accessLogEntry); ok {
+			e.route = route
+		}
+		h.ServeHTTP(w, r)
+	}), route)
+}
+
+func (a *accessLogger) serveHTTP(w http.ResponseWriter, r *http.Request, h http.Handler) {
+	if a.exclude[r.URL.Path] {
+		h.ServeHTTP(w, r)
+		return
+	}
+
+	start := time.Now()
+	entry := &accessLogEntry{}
+	ctx := context.WithValue(r.Context(), accessLogKey, entry)
+	r = r.WithContext(ctx)
+	body := &countingReader{ReadCloser: r.Body}
+	if r.Body != nil {
+		r.Body = body
+	}
+	rw := &accessLogWriter{ResponseWriter: w}
+
+	h.ServeHTTP(rw, r)
+
+	code := rw.status
+	if code == 0 {
+		code = http.StatusOK
+	}
+	if !a.sample(code) {
+		return
+	}
+	latency := time.Since(start)
+	keyvals := []interface{}{
+		"message", "access",
+		"route", entry.route,
+		"status", code,
+		"latency", latency.String(),
+		"bytes-in", body.n,
+		"bytes-out", rw.n,
+	}
+	if a.gcp {
+		keyvals = append(keyvals, httpRequestLogKey, &httpRequestLog{
+			RequestMethod: r.Method,
+			RequestURL:    r.URL.String(),
+			RequestSize:   body.n,
+			Status:        code,
+			ResponseSize:  rw.n,
+			UserAgent:     r.UserAgent(),
+			RemoteIP:      r.RemoteAddr,
+			Protocol:      r.Proto,
+			Latency:       fmt.Sprintf("%.9fs", latency.Seconds()),
+			latency:       latency,
+		})
+	}
+	a.log(ctx, code, keyvals)
+}
+
+// logRPC will log a completed gRPC request. The request and response sizes are
+// only logged for unary requests.
+func (a *accessLogger) logRPC(ctx context.Context, method string, start time.Time, err error, req, resp interface{}) {
+	if a == nil || a.exclude[method] {
+		return
+	}
+	grpcCode := status.Code(err)
+	code := httpStatusFromCode(grpcCode)
+	if !a.sample(code) {
+		return
+	}
+	latency := time.Since(start)
+	keyvals := []interface{}{
+		"message", "access",
+		"route", method,
+		"grpc-code", grpcCode.String(),
+		"latency", latency.String(),
+	}
+	var in, out int
+	if m, ok := req.(proto.Message); ok {
+		in = proto.Size(m)
+		keyvals = append(keyvals, "bytes-in", in)
+	}
+	if m, ok := resp.(proto.Message); ok {
+		out = proto.Size(m)
+		keyvals = append(keyvals, "bytes-out", out)
+	}
+	if a.gcp {
+		keyvals = append(keyvals, httpRequestLogKey, &httpRequestLog{
+			RequestMethod: http.MethodPost,
+			RequestURL:    method,
+			RequestSize:   int64(in),
+			Status:        code,
+			ResponseSize:  int64(out),
+			Protocol:      "HTTP/2",
+			Latency:       fmt.Sprintf("%.9fs", latency.Seconds()),
+			latency:       latency,
+		})
+	}
+	a.log(ctx, code, keyvals)
+}
+
+func (a *accessLogger) sample(code int) bool {
+	// server errors are always logged
+	return code >= http.StatusInternalServerError || a.sampleRate >= 1 ||
+		a.rand() < a.sampleRate
+}
+
+func (a *accessLogger) log(ctx context.Context, code int, keyvals []interface{}) {
+	if span := trace.FromContext(ctx); span != nil {
+		keyvals = append(keyvals, "trace-id", span.SpanContext().TraceID.String())
+	}
+	lg := level.Info(Logger(ctx))
+	if code >= http.StatusInternalServerError {
+		lg = level.Error(Logger(ctx))
+	}
+	lg.Log(keyvals...)
+}
+
+// httpRequestLog is the httpRequest field of Stackdriver's structured logs.
+// See https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#HttpRequest
+type httpRequestLog struct {
+	RequestMethod string `json:"requestMethod"`
+	RequestURL    string `json:"requestUrl"`
+	RequestSize   int64  `json:"requestSize,string"`
+	Status        int    `json:"status"`
+	ResponseSize  int64  `json:"responseSize,string"`
+	UserAgent     string `json:"userAgent,omitempty"`
+	RemoteIP      string `json:"remoteIp,omitempty"`
+	Protocol      string `json:"protocol"`
+	Latency       string `json:"latency"`
+
+	latency time.Duration
+}
+
+// stackdriver converts the log to a Stackdriver log entry's HTTPRequest.
+func (h *httpRequestLog) stackdriver() *logging.HTTPRequest {
+	u, err := url.Parse(h.RequestURL)
+	if err != nil {
+		u = &url.URL{Path: h.RequestURL}
+	}
+	return &logging.HTTPRequest{
+		Request: &http.Request{
+			Method: h.RequestMethod,
+			URL:    u,
+			Proto:  h.Protocol,
+			Header: http.Header{"User-Agent": []string{h.UserAgent}},
+		},
+		RequestSize:  h.RequestSize,
+		Status:       h.Status,
+		ResponseSize: h.ResponseSize,
+		Latency:      h.latency,
+		RemoteIP:     h.RemoteIP,
+	}
+}
+
+// countingReader counts the bytes read from a request body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// accessLogWriter records the status code and bytes written to a response.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	n      int64
+}
+
+func (w *accessLogWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *accessLogWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *accessLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, fmt.Errorf("%T does not support hijacking", w.ResponseWriter)
+}
+
+func (w *accessLogWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
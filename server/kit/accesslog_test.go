@@ -0,0 +1,178 @@
+package kit
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+)
+
+func TestAccessLog(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.AccessLog = true
+	cfg.AccessLogSampleRate = 0.5
+	cfg.HTTPAddr, cfg.HTTPPort, cfg.RPCPort = "127.0.0.1", 0, 0
+
+	svc := &accessLogService{streamService: &streamService{}}
+	svr := NewServerWithConfig(svc, cfg)
+	// sample out every successful request with a 0.5 rate
+	svr.accessLog.rand = func() float64 { return 0.9 }
+	if err := svr.Start(context.Background()); err != nil {
+		t.Fatalf("unable to start server: %s", err)
+	}
+	defer svr.Stop(context.Background())
+	url := "http://" + svr.Addr()
+
+	resp, err := http.Post(url+"/cats/ziggy", "text/plain", strings.NewReader("meow"))
+	if err != nil {
+		t.Fatalf("unable to make request: %s", err)
+	}
+	resp.Body.Close()
+	if got := svc.accessLogs(); len(got) != 0 {
+		t.Errorf("expected successful request to be sampled out, got %v", got)
+	}
+
+	svr.accessLog.rand = func() float64 { return 0.1 }
+	svr.accessLog.gcp = true
+	for _, path := range []string{"/healthz", "/readyz", "/cats/ziggy"} {
+		resp, err = http.Post(url+path, "text/plain", strings.NewReader("meow"))
+		if err != nil {
+			t.Fatalf("unable to make request: %s", err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	logs := svc.accessLogs()
+	if len(logs) != 1 {
+		t.Fatalf("expected 1 access log excluding health checks, got %v", logs)
+	}
+	got := logs[0]
+	want := map[string]interface{}{
+		"route":     "/cats/{name}",
+		"status":    http.StatusCreated,
+		"bytes-in":  int64(4),
+		"bytes-out": int64(len("\"ziggy\"\n")),
+		"level":     "info",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("expected %s of %v, got %v", k, v, got[k])
+		}
+	}
+	for _, k := range []string{"latency", "trace-id", "http-user-agent", "http-method"} {
+		if got[k] == nil {
+			t.Errorf("expected %s to be logged", k)
+		}
+	}
+	hr, ok := got[httpRequestLogKey].(*httpRequestLog)
+	if !ok {
+		t.Fatalf("expected httpRequest field, got %T", got[httpRequestLogKey])
+	}
+	if sd := hr.stackdriver(); sd.Status != http.StatusCreated ||
+		sd.Request.Method != http.MethodPost || sd.Request.URL.Path != "/cats/ziggy" {
+		t.Errorf("unexpected Stackdriver HTTP request: %+v", sd)
+	}
+
+	// server errors are always logged
+	resp, err = http.Get(url + "/error")
+	if err != nil {
+		t.Fatalf("unable to make request: %s", err)
+	}
+	resp.Body.Close()
+	logs = svc.accessLogs()
+	if len(logs) != 2 || logs[1]["status"] != http.StatusInternalServerError ||
+		logs[1]["level"] != "error" {
+		t.Errorf("expected server error to be logged, got %v", logs)
+	}
+
+	// gRPC
+	cc, err := grpc.Dial(svr.RPCAddr(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("unable to init gRPC connection: %s", err)
+	}
+	defer cc.Close()
+	stream, err := cc.NewStream(context.Background(), &streamServiceDesc.Streams[0],
+		"/kit_test.StreamService/Echo")
+	if err != nil {
+		t.Fatalf("unable to open stream: %s", err)
+	}
+	stream.SendMsg(&wrappers.StringValue{Value: "ziggy"})
+	stream.CloseSend()
+	var out wrappers.StringValue
+	if err = stream.RecvMsg(&out); err != nil {
+		t.Fatalf("unable to receive message: %s", err)
+	}
+	// wait for the stream to finish
+	stream.RecvMsg(&out)
+
+	logs = svc.accessLogs()
+	if len(logs) != 3 {
+		t.Fatalf("expected gRPC request to be logged, got %v", logs)
+	}
+	if logs[2]["route"] != "/kit_test.StreamService/Echo" || logs[2]["grpc-code"] != "OK" {
+		t.Errorf("unexpected gRPC access log: %v", logs[2])
+	}
+}
+
+type accessLogService struct {
+	*streamService
+
+	mu   sync.Mutex
+	logs []map[string]interface{}
+}
+
+func (s *accessLogService) accessLogs() []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]interface{}(nil), s.logs...)
+}
+
+func (s *accessLogService) LogBackend() log.Logger {
+	return log.LoggerFunc(func(keyvals ...interface{}) error {
+		m := map[string]interface{}{}
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			v := keyvals[i+1]
+			if k := keyvals[i]; k == "level" {
+				v = v.(interface{ String() string }).String()
+			}
+			m[keyvals[i].(string)] = v
+		}
+		if m["message"] == "access" {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.logs = append(s.logs, m)
+		}
+		return nil
+	})
+}
+
+func (s *accessLogService) HTTPEndpoints() map[string]map[string]HTTPEndpoint {
+	return map[string]map[string]HTTPEndpoint{
+		"/cats/{name}": {"POST": {
+			Decoder: func(ctx context.Context, r *http.Request) (interface{}, error) {
+				ioutil.ReadAll(r.Body)
+				return Vars(r)["name"], nil
+			},
+			Endpoint: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return createdName(req.(string)), nil
+			},
+		}},
+		"/error": {"GET": {
+			Endpoint: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, errors.New("broken")
+			},
+		}},
+	}
+}
+
+type createdName string
+
+func (createdName) StatusCode() int { return http.StatusCreated }
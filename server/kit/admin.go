@@ -0,0 +1,138 @@
+package kit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/NYTimes/gizmo/observe"
+	"github.com/pkg/errors"
+)
+
+// newAdminServer returns the server for the admin listener or nil if it is not
+// enabled.
+func (s *Server) newAdminServer() *http.Server {
+	if s.cfg.AdminPort == 0 {
+		return nil
+	}
+
+	mx := RouterSelect("")(nil)
+	registerPprof(s.cfg, mx)
+	if err := registerMetrics(s.cfg, mx, s.logger); err != nil {
+		s.logger.Log("error", err, "message", "unable to register metrics endpoint")
+	}
+	// health checks are served by the service's router in case they are overridden
+	mx.Handle(http.MethodGet, s.cfg.HealthCheckPath, s)
+	mx.Handle(http.MethodGet, s.cfg.ReadinessPath, s)
+	mx.HandleFunc(http.MethodGet, "/buildinfo", serveBuildInfo)
+	mx.HandleFunc(http.MethodGet, "/loglevel", s.serveLogLevel)
+	mx.HandleFunc(http.MethodPut, "/loglevel", s.serveLogLevel)
+
+	return &http.Server{
+		Handler:        mx,
+		Addr:           fmt.Sprintf("%s:%d", s.cfg.AdminAddr, s.cfg.AdminPort),
+		MaxHeaderBytes: s.cfg.MaxHeaderBytes,
+		ReadTimeout:    s.cfg.ReadTimeout,
+		IdleTimeout:    s.cfg.IdleTimeout,
+		// no WriteTimeout since CPU profiles and traces can take longer
+	}
+}
+
+// listenAdmin will bind the admin listener if it is enabled.
+func (s *Server) listenAdmin(ctx context.Context) (net.Listener, error) {
+	if s.adminSvr == nil {
+		return nil, nil
+	}
+	var lc net.ListenConfig
+	lis, err := lc.Listen(ctx, "tcp", s.adminSvr.Addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to listen to admin port")
+	}
+	return lis, nil
+}
+
+func (s *Server) serveAdmin(lis net.Listener) {
+	s.adminAddr = lis.Addr().String()
+	go func() {
+		// the admin server is not critical, so errors do not shut down the server
+		if err := s.adminSvr.Serve(lis); err != nil && err != http.ErrServerClosed {
+			s.logger.Log("error", err, "message", "admin server error")
+		}
+	}()
+	s.logger.Log("message",
+		fmt.Sprintf("listening on admin port: %d", listenerPort(lis)))
+}
+
+// stopAdmin will shut down the admin server, if any.
+func (s *Server) stopAdmin(ctx context.Context) {
+	if s.adminSvr == nil {
+		return
+	}
+	if err := s.adminSvr.Shutdown(ctx); err != nil {
+		s.adminSvr.Close()
+	}
+}
+
+// AdminAddr returns the address the admin server is listening on. It is empty
+// until the server is started or if the admin listener is not enabled.
+func (s *Server) AdminAddr() string {
+	return s.adminAddr
+}
+
+// BuildInfo is the response of the admin listener's /buildinfo endpoint.
+type BuildInfo struct {
+	Service   string `json:"service,omitempty"`
+	Version   string `json:"version,omitempty"`
+	GoVersion string `json:"go_version"`
+	Path      string `json:"path,omitempty"`
+	Module    string `json:"module,omitempty"`
+	ModuleSum string `json:"module_sum,omitempty"`
+}
+
+func serveBuildInfo(w http.ResponseWriter, r *http.Request) {
+	_, svc, version := observe.GetServiceInfo()
+	info := BuildInfo{
+		Service:   svc,
+		Version:   version,
+		GoVersion: runtime.Version(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.Path = bi.Path
+		info.Module = bi.Main.Path + "@" + bi.Main.Version
+		info.ModuleSum = bi.Main.Sum
+	}
+	writeAdminJSON(w, http.StatusOK, info)
+}
+
+// LogLevel is the request and response of the admin listener's /loglevel
+// endpoint. A PUT request will change the lowest level of logs written by the
+// server.
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+func (s *Server) serveLogLevel(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		var req LogLevel
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeAdminJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := s.logLevel.setLevel(req.Level); err != nil {
+			writeAdminJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		s.logger.Log("message", "log level changed", "level", s.logLevel.level())
+	}
+	writeAdminJSON(w, http.StatusOK, LogLevel{Level: s.logLevel.level()})
+}
+
+func writeAdminJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
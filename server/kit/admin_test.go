@@ -0,0 +1,89 @@
+package kit
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestKitServerAdmin(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.HTTPAddr, cfg.HTTPPort, cfg.RPCPort = "127.0.0.1", 0, 0
+	cfg.AdminAddr, cfg.AdminPort = "127.0.0.1", freePort(t)
+	cfg.EnablePProf, cfg.EnableMetrics = true, true
+
+	svc := &logService{streamService: &streamService{}}
+	svr := NewServerWithConfig(svc, cfg)
+	if err := svr.Start(context.Background()); err != nil {
+		t.Fatalf("unable to start server: %s", err)
+	}
+	defer svr.Stop(context.Background())
+	public, admin := "http://"+svr.Addr(), "http://"+svr.AdminAddr()
+
+	get := func(url string) (int, []byte) {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("unable to make request: %s", err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, b
+	}
+
+	for _, path := range []string{
+		"/debug/pprof/", "/debug/pprof/allocs", "/debug/pprof/mutex",
+		"/metrics", "/healthz", "/readyz", "/buildinfo",
+	} {
+		if code, _ := get(admin + path); code != http.StatusOK {
+			t.Errorf("expected status code of 200 for admin %s, got %d", path, code)
+		}
+	}
+	// operational endpoints are not public
+	for _, path := range []string{"/debug/pprof/", "/metrics"} {
+		if code, _ := get(public + path); code != http.StatusNotFound {
+			t.Errorf("expected status code of 404 for public %s, got %d", path, code)
+		}
+	}
+
+	var info BuildInfo
+	_, b := get(admin + "/buildinfo")
+	if err := json.Unmarshal(b, &info); err != nil || !strings.HasPrefix(info.GoVersion, "go") {
+		t.Errorf("unexpected build info %s: %v", b, err)
+	}
+
+	setLevel := func(lvl string) (int, LogLevel) {
+		req, _ := http.NewRequest(http.MethodPut, admin+"/loglevel",
+			strings.NewReader(`{"level":"`+lvl+`"}`))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unable to make request: %s", err)
+		}
+		defer resp.Body.Close()
+		var got LogLevel
+		json.NewDecoder(resp.Body).Decode(&got)
+		return resp.StatusCode, got
+	}
+	if code, _ := setLevel("verbose"); code != http.StatusBadRequest {
+		t.Errorf("expected status code of 400 for invalid level, got %d", code)
+	}
+	if code, got := setLevel("warn"); code != http.StatusOK || got.Level != "warn" {
+		t.Errorf("expected log level to be changed to warn, got %d %+v", code, got)
+	}
+	var got LogLevel
+	_, b = get(admin + "/loglevel")
+	if json.Unmarshal(b, &got); got.Level != "warn" {
+		t.Errorf("expected log level of warn, got %q", got.Level)
+	}
+
+	// info logs are now dropped
+	svr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/log", nil))
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	if len(svc.logs) != 0 {
+		t.Errorf("expected info logs to be dropped, got %v", svc.logs)
+	}
+}
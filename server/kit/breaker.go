@@ -0,0 +1,135 @@
+package kit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrCircuitOpen is returned by endpoints guarded by an open CircuitBreaker.
+var ErrCircuitOpen = NewError(codes.Unavailable, "circuit breaker is open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// breakerResult is the outcome of a call guarded by a CircuitBreaker.
+type breakerResult int
+
+const (
+	breakerSuccess breakerResult = iota
+	breakerFailure
+	// breakerNeutral calls, like canceled ones, say nothing about the health
+	// of the dependency.
+	breakerNeutral
+)
+
+// CircuitBreaker stops calls to a failing dependency. After a number of
+// consecutive failures the breaker opens and calls fail fast with ErrCircuitOpen.
+// Once the cooldown has passed a single call is let through and, if it
+// succeeds, the breaker closes again.
+//
+// Network errors and Errors with an Unavailable, DeadlineExceeded, Internal,
+// Unknown or ResourceExhausted code, as well as panics, are counted as failures.
+// Canceled calls are neither failures nor successes: a canceled probe leaves
+// the breaker open and the next call will probe the dependency instead.
+type CircuitBreaker struct {
+	failures int
+	cooldown time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	count    int
+	openedAt time.Time
+
+	// now is replaceable for testing
+	now func() time.Time
+}
+
+// NewCircuitBreaker returns a CircuitBreaker that opens after the given number of
+// consecutive failures and lets a call through again after the cooldown.
+func NewCircuitBreaker(failures int, cooldown time.Duration) *CircuitBreaker {
+	if failures < 1 {
+		failures = 1
+	}
+	return &CircuitBreaker{failures: failures, cooldown: cooldown, now: time.Now}
+}
+
+// Middleware returns an endpoint.Middleware guarded by the breaker.
+func (b *CircuitBreaker) Middleware(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, req interface{}) (res interface{}, err error) {
+		if !b.allow() {
+			return nil, ErrCircuitOpen
+		}
+		// a panic is counted as a failure
+		result := breakerFailure
+		defer func() { b.record(result) }()
+		res, err = next(ctx, req)
+		result = breakerResultOf(err)
+		return res, err
+	}
+}
+
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		// probe the dependency with a single call
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		return false
+	}
+	return true
+}
+
+func (b *CircuitBreaker) record(result breakerResult) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch result {
+	case breakerSuccess:
+		b.state, b.count = breakerClosed, 0
+	case breakerNeutral:
+		if b.state == breakerHalfOpen {
+			// the cooldown has passed, so the next call will probe again
+			b.state = breakerOpen
+		}
+	default:
+		b.count++
+		if b.state == breakerHalfOpen || b.count >= b.failures {
+			b.state, b.openedAt = breakerOpen, b.now()
+		}
+	}
+}
+
+func breakerResultOf(err error) breakerResult {
+	if err == nil {
+		return breakerSuccess
+	}
+	if errors.Cause(err) == context.Canceled {
+		return breakerNeutral
+	}
+	if e, ok := errors.Cause(err).(*Error); ok {
+		switch e.Code {
+		case codes.Canceled:
+			return breakerNeutral
+		case codes.Unavailable, codes.DeadlineExceeded, codes.Internal,
+			codes.Unknown, codes.ResourceExhausted:
+			return breakerFailure
+		}
+		return breakerSuccess
+	}
+	return breakerFailure
+}
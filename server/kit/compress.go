@@ -0,0 +1,366 @@
+package kit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+
+	// register the gzip compressor for RPCs
+	_ "google.golang.org/grpc/encoding/gzip"
+)
+
+// ErrRequestTooLarge is returned when reading a decompressed request body larger
+// than the server's MaxDecompressedBytes. It is encoded as a 413 status.
+var ErrRequestTooLarge error = requestTooLargeError{}
+
+type requestTooLargeError struct{}
+
+func (requestTooLargeError) Error() string {
+	return "request body too large"
+}
+
+func (requestTooLargeError) StatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+// compressor is implemented by the gzip, brotli and zstd writers.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// compressors pools the writers of each supported encoding.
+var compressors = map[string]*sync.Pool{
+	"br": {New: func() interface{} {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}},
+	"zstd": {New: func() interface{} {
+		// a single goroutine per writer, the options are valid
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return enc
+	}},
+	"gzip": {New: func() interface{} {
+		return gzip.NewWriter(nil)
+	}},
+}
+
+// compression will compress responses and decompress requests according to
+// the Config.
+func compression(cfg Config, h http.Handler) http.Handler {
+	if !cfg.Compression && !cfg.DecompressRequests {
+		return h
+	}
+	var encodings []string
+	for _, enc := range cfg.CompressionEncodings {
+		enc = strings.ToLower(strings.TrimSpace(enc))
+		if _, ok := compressors[enc]; ok {
+			encodings = append(encodings, enc)
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.DecompressRequests {
+			if err := decompressRequest(r, cfg.MaxDecompressedBytes); err != nil {
+				EncodeErrorResponse(r.Context(), err, w)
+				return
+			}
+		}
+		if !cfg.Compression || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		enc := negotiateEncoding(r.Header.Get("Accept-Encoding"), encodings)
+		if enc == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{
+			ResponseWriter: w,
+			encoding:       enc,
+			minSize:        cfg.CompressionMinSize,
+			types:          cfg.CompressionTypes,
+		}
+		defer cw.close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding returns the encoding with the highest q-value in the
+// Accept-Encoding header. The server's preference breaks ties. It returns an
+// empty string if none are accepted.
+func negotiateEncoding(accept string, encodings []string) string {
+	if accept == "" {
+		return ""
+	}
+	qs := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		name, q := part, 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			name = part[:i]
+			param := strings.TrimSpace(part[i+1:])
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			var err error
+			if q, err = strconv.ParseFloat(param[2:], 64); err != nil {
+				continue
+			}
+		}
+		qs[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	var (
+		best  string
+		bestQ float64
+	)
+	for _, enc := range encodings {
+		q, ok := qs[enc]
+		if !ok {
+			q = qs["*"]
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the start of a response until it can decide whether to
+// compress it.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	types    []string
+
+	code    int
+	buf     []byte
+	decided bool
+	comp    compressor
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided || w.code != 0 {
+		return
+	}
+	if code < http.StatusOK && code != http.StatusSwitchingProtocols {
+		// informational responses are sent as is
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.code = code
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.minSize {
+			return len(b), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.comp != nil {
+		return w.comp.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide will write the headers and buffered body, compressed if the response
+// qualifies.
+func (w *compressWriter) decide() error {
+	w.decided = true
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	h := w.Header()
+	if len(w.buf) > 0 && h.Get("Content-Type") == "" {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if w.shouldCompress() {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		w.comp = compressors[w.encoding].Get().(compressor)
+		w.comp.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.comp != nil {
+		_, err = w.comp.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (w *compressWriter) shouldCompress() bool {
+	switch {
+	case len(w.buf) < w.minSize,
+		w.code == http.StatusNoContent || w.code == http.StatusNotModified ||
+			w.code == http.StatusPartialContent || w.code == http.StatusSwitchingProtocols,
+		w.Header().Get("Content-Encoding") != "":
+		return false
+	}
+	mt, _, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, t := range w.types {
+		t = strings.TrimSpace(t)
+		if t == mt || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mt, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		// the buffered body is sent uncompressed if it is below the minimum size
+		w.decide()
+	}
+	if w.comp != nil {
+		w.comp.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	// the handler takes over the connection
+	w.decided = true
+	return h.Hijack()
+}
+
+func (w *compressWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// close will finish the response once the handler returns.
+func (w *compressWriter) close() {
+	if !w.decided && w.code != 0 {
+		w.decide()
+	}
+	if w.comp == nil {
+		return
+	}
+	w.comp.Close()
+	// release the underlying writer before returning to the pool
+	w.comp.Reset(nil)
+	compressors[w.encoding].Put(w.comp)
+	w.comp = nil
+}
+
+// decompressRequest will replace the body of requests with a Content-Encoding
+// with a reader of the decompressed body that fails with ErrRequestTooLarge
+// after max bytes.
+func decompressRequest(r *http.Request, max int64) error {
+	enc := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if enc == "" || enc == "identity" || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	var (
+		body  io.Reader
+		close func()
+	)
+	switch enc {
+	case "gzip", "x-gzip":
+		gr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return Errorf(codes.InvalidArgument, "invalid gzip request body: %s", err)
+		}
+		body = gr
+	case "br":
+		body = brotli.NewReader(r.Body)
+	case "zstd":
+		zr, err := zstd.NewReader(r.Body, zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxMemory(uint64(max)))
+		if err != nil {
+			return Errorf(codes.InvalidArgument, "invalid zstd request body: %s", err)
+		}
+		body, close = zr, zr.Close
+	default:
+		return unsupportedEncodingError(enc)
+	}
+
+	r.Body = &decompressedBody{r: body, body: r.Body, close: close, remaining: max}
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return nil
+}
+
+// unsupportedEncodingError is encoded as a 415 status.
+type unsupportedEncodingError string
+
+func (e unsupportedEncodingError) Error() string {
+	return "unsupported content encoding " + strconv.Quote(string(e))
+}
+
+func (unsupportedEncodingError) StatusCode() int {
+	return http.StatusUnsupportedMediaType
+}
+
+// decompressedBody limits the size of a decompressed request body.
+type decompressedBody struct {
+	r         io.Reader
+	body      io.ReadCloser
+	close     func()
+	remaining int64
+}
+
+func (b *decompressedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// check for more data past the limit
+		var one [1]byte
+		if n, _ := b.r.Read(one[:]); n > 0 {
+			return 0, ErrRequestTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.r.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *decompressedBody) Close() error {
+	if b.close != nil {
+		b.close()
+	}
+	return b.body.Close()
+}
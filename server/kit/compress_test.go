@@ -0,0 +1,184 @@
+package kit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	encodings := []string{"br", "zstd", "gzip"}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"gzip;q=1.0, br;q=0.5", "gzip"},
+		{"br;q=0, gzip", "gzip"},
+		{"*", "br"},
+		{"*;q=0.1, zstd", "zstd"},
+		{"identity", ""},
+		{"deflate", ""},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.accept, encodings); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.Compression = true
+	body := `{"cats":"` + strings.Repeat("ziggy ", 500) + `"}`
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"zstd": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+
+	tests := []struct {
+		name     string
+		accept   string
+		respType string
+		respEnc  string
+		body     string
+
+		wantEnc string
+	}{
+		{"gzip", "gzip", "application/json", "", body, "gzip"},
+		{"brotli", "br, gzip", "application/json; charset=utf-8", "", body, "br"},
+		{"zstd", "zstd", "text/plain", "", body, "zstd"},
+		{"not accepted", "deflate", "application/json", "", body, ""},
+		{"too small", "gzip", "application/json", "", `{"cat":"ziggy"}`, ""},
+		{"excluded type", "gzip", "image/png", "", body, ""},
+		{"sniffed type", "gzip", "", "", body, "gzip"},
+		{"already encoded", "gzip", "application/json", "custom", body, "custom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := compression(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.respType != "" {
+					w.Header().Set("Content-Type", tt.respType)
+				}
+				if tt.respEnc != "" {
+					w.Header().Set("Content-Encoding", tt.respEnc)
+				}
+				w.WriteHeader(http.StatusCreated)
+				// write in chunks to exercise the buffering
+				for i := 0; i < len(tt.body); i += 100 {
+					end := i + 100
+					if end > len(tt.body) {
+						end = len(tt.body)
+					}
+					io.WriteString(w, tt.body[i:end])
+				}
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", tt.accept)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != http.StatusCreated {
+				t.Errorf("expected status code 201, got %d", w.Code)
+			}
+			if got := w.Header().Get("Content-Encoding"); got != tt.wantEnc {
+				t.Fatalf("expected Content-Encoding %q, got %q", tt.wantEnc, got)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("expected Vary header, got %q", got)
+			}
+			var rd io.Reader = w.Body
+			if dec, ok := decoders[tt.wantEnc]; ok {
+				var err error
+				if rd, err = dec(rd); err != nil {
+					t.Fatalf("unable to decode response: %s", err)
+				}
+			}
+			got, err := ioutil.ReadAll(rd)
+			if err != nil {
+				t.Fatalf("unable to read response: %s", err)
+			}
+			if string(got) != tt.body {
+				t.Errorf("unexpected response body of %d bytes", len(got))
+			}
+		})
+	}
+}
+
+func TestDecompression(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.DecompressRequests = true
+	cfg.MaxDecompressedBytes = 1000
+
+	gzipped := func(s string) []byte {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		io.WriteString(gw, s)
+		gw.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+
+		wantCode int
+		wantBody string
+		wantErr  error
+	}{
+		{"plain", "", []byte("hi there"), http.StatusOK, "hi there", nil},
+		{"gzip", "gzip", gzipped("hi there"), http.StatusOK, "hi there", nil},
+		{"too large", "gzip", gzipped(strings.Repeat("a", 1001)), http.StatusOK,
+			strings.Repeat("a", 1000), ErrRequestTooLarge},
+		{"invalid", "gzip", []byte("not gzip"), http.StatusBadRequest, "", nil},
+		{"unsupported", "compress", []byte("hi there"), http.StatusUnsupportedMediaType, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				got    []byte
+				gotErr error
+			)
+			h := compression(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if enc := r.Header.Get("Content-Encoding"); enc != "" {
+					t.Errorf("expected Content-Encoding to be removed, got %q", enc)
+				}
+				got, gotErr = ioutil.ReadAll(r.Body)
+			}))
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			r.Header.Set("Content-Encoding", tt.encoding)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("expected status code %d, got %d", tt.wantCode, w.Code)
+			}
+			if string(got) != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, got)
+			}
+			if gotErr != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, gotErr)
+			}
+		})
+	}
+}
+
+func TestRPCCompressorRegistered(t *testing.T) {
+	if encoding.GetCompressor("gzip") == nil {
+		t.Error("expected the gzip compressor to be registered")
+	}
+}
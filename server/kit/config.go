@@ -11,13 +11,26 @@ import (
 
 // Config holds info required to configure a gizmo kit.Server.
 //
-// This struct is loaded from the environment at Run and only made public to expose
-// for documentation.
+// This struct is loaded from the environment at Run. Users embedding a server may
+// load it with LoadConfig, adjust it and pass it to NewServerWithConfig.
 type Config struct {
 	// HealthCheckPath is used by server to init the proper HealthCheckHandler.
 	// If empty, this will default to '/healthz'.
 	HealthCheckPath string `envconfig:"GIZMO_HEALTH_CHECK_PATH"`
 
+	// ReadinessPath is used by the server to serve the readiness check, which runs
+	// the checks of services implementing HealthChecker. If empty, this will
+	// default to '/readyz'.
+	ReadinessPath string `envconfig:"GIZMO_READINESS_PATH"`
+
+	// HealthCheckTimeout is the time allowed for all readiness checks to complete.
+	// The default is 5s.
+	HealthCheckTimeout time.Duration `envconfig:"GIZMO_HEALTH_CHECK_TIMEOUT"`
+
+	// HealthCheckCacheTTL is how long readiness check results are reused for.
+	// The default is 1s.
+	HealthCheckCacheTTL time.Duration `envconfig:"GIZMO_HEALTH_CHECK_CACHE_TTL"`
+
 	// MaxHeaderBytes can be used to override the default of 1<<20.
 	MaxHeaderBytes int `envconfig:"GIZMO_MAX_HEADER_BYTES"`
 
@@ -37,6 +50,13 @@ type Config struct {
 	// of 5m.
 	ShutdownTimeout time.Duration `envconfig:"GIZMO_SHUTDOWN_TIMEOUT"`
 
+	// ShutdownDrainDelay is how long the server will keep serving requests after
+	// it begins to shut down and its readiness checks start failing. This gives
+	// load balancers time to stop routing traffic to the server before it stops
+	// accepting connections. The ShutdownTimeout starts after the delay. The
+	// default is 0.
+	ShutdownDrainDelay time.Duration `envconfig:"GIZMO_SHUTDOWN_DRAIN_DELAY"`
+
 	// GOMAXPROCS can be used to override the default GOMAXPROCS.
 	GOMAXPROCS int `envconfig:"GIZMO_GOMAXPROCS"`
 
@@ -50,11 +70,100 @@ type Config struct {
 	// The default is 8081.
 	RPCPort int `envconfig:"RPC_PORT"`
 
+	// SinglePort will serve gRPC, HTTP/1.1 and HTTP/2 cleartext (h2c) requests on
+	// HTTPPort instead of serving gRPC on RPCPort. Requests are dispatched by
+	// protocol and content-type. This is useful for environments like Cloud Run
+	// that only expose a single port. Off by default.
+	SinglePort bool `envconfig:"GIZMO_SINGLE_PORT"`
+
+	// TLSCertFile is the path to a PEM encoded certificate used to serve HTTP and
+	// gRPC over TLS. TLS is disabled unless both TLSCertFile and TLSKeyFile are set.
+	// The certificate will be reloaded within seconds of either file changing.
+	TLSCertFile string `envconfig:"TLS_CERT"`
+	// TLSKeyFile is the path to the PEM encoded private key of TLSCertFile.
+	TLSKeyFile string `envconfig:"TLS_KEY"`
+	// TLSClientCAFile is an optional path to a PEM encoded CA bundle. If set,
+	// clients will be required to present a certificate signed by one of the CAs
+	// and the verified certificate will be available via PeerCertificate.
+	TLSClientCAFile string `envconfig:"TLS_CLIENT_CA"`
+
+	// AccessLog enables structured access logs of HTTP and gRPC requests, written
+	// with the request scoped logger. Off by default.
+	AccessLog bool `envconfig:"GIZMO_ACCESS_LOG"`
+
+	// AccessLogExclude is a comma separated list of HTTP paths and gRPC methods,
+	// like "/pkg.Service/Method", that will not be access logged. The default is
+	// the HealthCheckPath, ReadinessPath and MetricsPath.
+	AccessLogExclude []string `envconfig:"GIZMO_ACCESS_LOG_EXCLUDE"`
+
+	// AccessLogSampleRate is the fraction of requests, between 0 and 1, that will
+	// be access logged. Requests that fail with a server error are always logged.
+	// The default is 1.
+	AccessLogSampleRate float64 `envconfig:"GIZMO_ACCESS_LOG_SAMPLE_RATE"`
+
+	// LogLevel is the lowest level of leveled logs that will be written: "debug",
+	// "info", "warn" or "error". Logs without a level are always written. The
+	// default is "debug".
+	LogLevel string `envconfig:"GIZMO_LOG_LEVEL"`
+
+	// LogFormat is the format of logs written to stdout: "json" or "logfmt". It
+	// has no effect when logging to Stackdriver or a custom backend. The default
+	// is "json".
+	LogFormat string `envconfig:"GIZMO_LOG_FORMAT"`
+
+	// LogSampleInitial enables sampling of high-volume logs. Each second, the
+	// first LogSampleInitial logs with the same level and message are written
+	// and then only every LogSampleThereafter-th one. Error logs and logs without
+	// a message are never sampled. Sampling is off by default.
+	LogSampleInitial    int `envconfig:"GIZMO_LOG_SAMPLE_INITIAL"`
+	LogSampleThereafter int `envconfig:"GIZMO_LOG_SAMPLE_THEREAFTER"`
+
 	// Enable pprof Profiling. Off by default.
 	EnablePProf bool `envconfig:"ENABLE_PPROF"`
+
+	// AdminPort will serve operational endpoints on a separate listener when set:
+	// pprof (if EnablePProf), metrics (if EnableMetrics), the health and readiness
+	// checks, build info on /buildinfo and the log level on /loglevel, which can
+	// be changed at runtime with a PUT request like {"level":"debug"}. pprof and
+	// metrics will then no longer be served on the HTTPPort. Off by default.
+	AdminPort int `envconfig:"GIZMO_ADMIN_PORT"`
+	// AdminAddr is the address the admin listener will bind to. The default is ""
+	// (bind to all interfaces).
+	AdminAddr string `envconfig:"GIZMO_ADMIN_ADDR"`
+
+	// EnableMetrics will serve the server's HTTP and gRPC metrics in the
+	// Prometheus format on MetricsPath. Off by default.
+	EnableMetrics bool `envconfig:"ENABLE_METRICS"`
+	// MetricsPath is the path metrics are served on. The default is "/metrics".
+	MetricsPath string `envconfig:"GIZMO_METRICS_PATH"`
+
+	// Compression enables compression of HTTP responses with the first of the
+	// CompressionEncodings the client accepts. Off by default. gzip compressed
+	// RPCs are always accepted and answered with gzip compressed responses.
+	Compression bool `envconfig:"GIZMO_COMPRESSION"`
+	// CompressionEncodings is a comma separated list of the response encodings
+	// in order of preference: "br", "zstd" and "gzip". The default is all three
+	// in that order.
+	CompressionEncodings []string `envconfig:"GIZMO_COMPRESSION_ENCODINGS"`
+	// CompressionMinSize is the smallest response body, in bytes, that will be
+	// compressed. The default is 1024.
+	CompressionMinSize int `envconfig:"GIZMO_COMPRESSION_MIN_SIZE"`
+	// CompressionTypes is a comma separated list of the content types that will
+	// be compressed. Types like "text/*" match any subtype. The default is text,
+	// JSON, JavaScript, XML, SVG and Protobuf.
+	CompressionTypes []string `envconfig:"GIZMO_COMPRESSION_TYPES"`
+
+	// DecompressRequests will transparently decompress request bodies with a
+	// "gzip", "br" or "zstd" Content-Encoding. Requests with other encodings are
+	// rejected. Off by default.
+	DecompressRequests bool `envconfig:"GIZMO_DECOMPRESS_REQUESTS"`
+	// MaxDecompressedBytes is the largest decompressed request body that will be
+	// read. Reading past it fails with ErrRequestTooLarge. The default is 10MiB.
+	MaxDecompressedBytes int64 `envconfig:"GIZMO_MAX_DECOMPRESSED_BYTES"`
 }
 
-func loadConfig() Config {
+// LoadConfig will load the Config from the environment with defaults set.
+func LoadConfig() Config {
 	var cfg Config
 	envconfig.MustProcess("", &cfg)
 	if cfg.HTTPPort == 0 {
@@ -86,6 +195,37 @@ func loadConfig() Config {
 	if cfg.HealthCheckPath == "" {
 		cfg.HealthCheckPath = "/healthz"
 	}
+	if cfg.ReadinessPath == "" {
+		cfg.ReadinessPath = "/readyz"
+	}
+	if cfg.HealthCheckTimeout.Nanoseconds() == 0 {
+		cfg.HealthCheckTimeout = 5 * time.Second
+	}
+	if cfg.HealthCheckCacheTTL.Nanoseconds() == 0 {
+		cfg.HealthCheckCacheTTL = time.Second
+	}
+	if cfg.MetricsPath == "" {
+		cfg.MetricsPath = "/metrics"
+	}
+	if cfg.AccessLogExclude == nil {
+		cfg.AccessLogExclude = []string{cfg.HealthCheckPath, cfg.ReadinessPath, cfg.MetricsPath}
+	}
+	if cfg.AccessLogSampleRate == 0 {
+		cfg.AccessLogSampleRate = 1
+	}
+	if cfg.CompressionEncodings == nil {
+		cfg.CompressionEncodings = []string{"br", "zstd", "gzip"}
+	}
+	if cfg.CompressionMinSize == 0 {
+		cfg.CompressionMinSize = 1024
+	}
+	if cfg.CompressionTypes == nil {
+		cfg.CompressionTypes = []string{"text/*", "application/json", "application/javascript",
+			"application/xml", "image/svg+xml", "application/x-protobuf"}
+	}
+	if cfg.MaxDecompressedBytes == 0 {
+		cfg.MaxDecompressedBytes = 10 << 20
+	}
 	if cfg.ShutdownTimeout.Nanoseconds() == 0 {
 		cfg.ShutdownTimeout = 5 * time.Minute
 	}
@@ -0,0 +1,265 @@
+package kit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Error is an error with a gRPC status code, a message and optional Protobuf
+// details that can be returned from both HTTP endpoints and gRPC methods.
+//
+// Over HTTP, EncodeErrorResponse will respond with the HTTP status equivalent to
+// the Code and a `google.rpc.Status` body encoded as JSON or, if requested via the
+// Accept header, as Protobuf. Over gRPC, the kit server will respond with the
+// equivalent status.
+type Error struct {
+	Code    codes.Code
+	Message string
+	Details []proto.Message
+}
+
+// NewError will return an Error with the given code, message and details.
+func NewError(code codes.Code, msg string, details ...proto.Message) *Error {
+	return &Error{Code: code, Message: msg, Details: details}
+}
+
+// Errorf will return an Error with the given code and a formatted message.
+func Errorf(code codes.Code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// StatusCode implements httptransport.StatusCoder and returns the HTTP status
+// equivalent to the Code.
+func (e *Error) StatusCode() int {
+	return httpStatusFromCode(e.Code)
+}
+
+// GRPCStatus returns the gRPC status of the error. Any details that can't be
+// attached to the status are dropped.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(e.Code, e.Message)
+	if len(e.Details) == 0 {
+		return st
+	}
+	if dst, err := st.WithDetails(e.Details...); err == nil {
+		return dst
+	}
+	return st
+}
+
+// FromRPCError will convert an error returned by a gRPC client into an *Error,
+// unpacking any details that are registered Protobuf types. It returns nil if
+// err is nil.
+func FromRPCError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	if e, ok := errors.Cause(err).(*Error); ok {
+		return e
+	}
+	return fromStatus(status.Convert(err))
+}
+
+// DecodeErrorResponse will decode an error written by EncodeErrorResponse from
+// an HTTP response. If the body can't be decoded, the Error will use the code
+// equivalent to the HTTP status. It returns nil for non-error responses. The
+// response body is read but not closed.
+func DecodeErrorResponse(res *http.Response) *Error {
+	if res.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	e := &Error{
+		Code:    codeFromHTTPStatus(res.StatusCode),
+		Message: http.StatusText(res.StatusCode),
+	}
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil || len(b) == 0 {
+		return e
+	}
+
+	var st spb.Status
+	if isProtoContentType(res.Header.Get("Content-Type")) {
+		err = proto.Unmarshal(b, &st)
+	} else {
+		err = protojson.Unmarshal(b, proto.MessageV2(&st))
+	}
+	if err == nil {
+		return fromStatus(status.FromProto(&st))
+	}
+
+	// the details may not be registered types, fall back to the code and message
+	var body struct {
+		Code    codes.Code `json:"code"`
+		Message string     `json:"message"`
+	}
+	if json.Unmarshal(b, &body) == nil && body.Code != codes.OK {
+		e.Code, e.Message = body.Code, body.Message
+	}
+	return e
+}
+
+func fromStatus(st *status.Status) *Error {
+	e := &Error{Code: st.Code(), Message: st.Message()}
+	for _, d := range st.Details() {
+		if m, ok := d.(proto.Message); ok {
+			e.Details = append(e.Details, m)
+		}
+	}
+	return e
+}
+
+// grpcStatuser is implemented by errors that can be converted to a gRPC status,
+// like *Error.
+type grpcStatuser interface {
+	GRPCStatus() *status.Status
+}
+
+// EncodeErrorResponse is an httptransport.ErrorEncoder and the default error
+// encoder of the kit server. Errors that implement httptransport.StatusCoder
+// but have no gRPC status, like JSONStatusResponse and ProtoStatusResponse, are
+// encoded by go-kit's DefaultErrorEncoder. All other errors are converted to a
+// gRPC status and encoded as a `google.rpc.Status` with the equivalent HTTP
+// status code. Headers of errors that implement httptransport.Headerer are
+// added to the response.
+func EncodeErrorResponse(ctx context.Context, err error, w http.ResponseWriter) {
+	cause := errors.Cause(err)
+	if _, ok := cause.(grpcStatuser); !ok {
+		if _, ok := cause.(httptransport.StatusCoder); ok {
+			httptransport.DefaultErrorEncoder(ctx, cause, w)
+			return
+		}
+	}
+
+	st := status.Convert(toRPCError(err))
+	if headerer, ok := cause.(httptransport.Headerer); ok {
+		for k, values := range headerer.Headers() {
+			for _, v := range values {
+				w.Header().Add(k, v)
+			}
+		}
+	}
+
+	var (
+		b    []byte
+		merr error
+	)
+	if acceptsProto(ctx) {
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		b, merr = proto.Marshal(st.Proto())
+	} else {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		b, merr = protojson.Marshal(proto.MessageV2(st.Proto()))
+	}
+	if merr != nil {
+		// unable to marshal the details, respond without them
+		st = status.New(st.Code(), st.Message())
+		b, _ = json.Marshal(map[string]interface{}{
+			"code":    st.Code(),
+			"message": st.Message(),
+		})
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+	w.WriteHeader(httpStatusFromCode(st.Code()))
+	w.Write(b)
+}
+
+// toRPCError will convert errors returned by endpoints into gRPC status errors.
+func toRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+	cause := errors.Cause(err)
+	if e, ok := cause.(grpcStatuser); ok {
+		return e.GRPCStatus().Err()
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch cause {
+	case context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	if sc, ok := cause.(httptransport.StatusCoder); ok {
+		return status.Error(codeFromHTTPStatus(sc.StatusCode()), err.Error())
+	}
+	return err
+}
+
+// httpStatusFromCode maps a gRPC status code to the equivalent HTTP status.
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// codeFromHTTPStatus maps an HTTP status to the equivalent gRPC status code.
+func codeFromHTTPStatus(code int) codes.Code {
+	switch code {
+	case http.StatusOK:
+		return codes.OK
+	case 499:
+		return codes.Canceled
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusGatewayTimeout, http.StatusRequestTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusTooManyRequests, http.StatusRequestEntityTooLarge:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		return codes.Unavailable
+	}
+	if code >= 200 && code < 300 {
+		return codes.OK
+	}
+	return codes.Unknown
+}
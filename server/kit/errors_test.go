@@ -0,0 +1,153 @@
+package kit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestEncodeErrorResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		accept string
+
+		wantStatus int
+		wantErr    *Error
+	}{
+		{
+			name:       "kit error",
+			err:        NewError(codes.NotFound, "no cat", &wrappers.StringValue{Value: "ziggy"}),
+			wantStatus: http.StatusNotFound,
+			wantErr:    NewError(codes.NotFound, "no cat", &wrappers.StringValue{Value: "ziggy"}),
+		},
+		{
+			name:       "wrapped kit error as proto",
+			err:        errors.Wrap(Errorf(codes.InvalidArgument, "bad %s", "cat"), "oops"),
+			accept:     "application/x-protobuf",
+			wantStatus: http.StatusBadRequest,
+			wantErr:    NewError(codes.InvalidArgument, "bad cat"),
+		},
+		{
+			name:       "status error",
+			err:        status.Error(codes.PermissionDenied, "no"),
+			wantStatus: http.StatusForbidden,
+			wantErr:    NewError(codes.PermissionDenied, "no"),
+		},
+		{
+			name:       "plain error",
+			err:        errors.New("doh"),
+			wantStatus: http.StatusInternalServerError,
+			wantErr:    NewError(codes.Unknown, "doh"),
+		},
+		{
+			name:       "status response",
+			err:        NewJSONStatusResponse(map[string]string{"error": "gone"}, http.StatusGone),
+			wantStatus: http.StatusGone,
+			wantErr:    NewError(codes.Unknown, http.StatusText(http.StatusGone)),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svr := NewServer(&errorService{streamService: &streamService{}, err: test.err})
+
+			r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/error", nil)
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			w := httptest.NewRecorder()
+			svr.ServeHTTP(w, r)
+
+			res := w.Result()
+			if res.StatusCode != test.wantStatus {
+				t.Fatalf("expected status code of %d, got %d", test.wantStatus, res.StatusCode)
+			}
+			got := DecodeErrorResponse(res)
+			if got.Code != test.wantErr.Code || got.Message != test.wantErr.Message {
+				t.Errorf("expected error %s, got %s", test.wantErr, got)
+			}
+			if len(got.Details) != len(test.wantErr.Details) {
+				t.Fatalf("expected %d details, got %d", len(test.wantErr.Details), len(got.Details))
+			}
+			for i := range got.Details {
+				if !proto.Equal(got.Details[i], test.wantErr.Details[i]) {
+					t.Errorf("expected detail %v, got %v", test.wantErr.Details[i], got.Details[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRPCErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want *Error
+	}{
+		{
+			name: "kit error",
+			err:  errors.Wrap(NewError(codes.NotFound, "no cat", &wrappers.StringValue{Value: "ziggy"}), "oops"),
+			want: NewError(codes.NotFound, "no cat", &wrappers.StringValue{Value: "ziggy"}),
+		},
+		{
+			name: "context error",
+			err:  errors.Wrap(context.DeadlineExceeded, "slow"),
+			want: NewError(codes.DeadlineExceeded, "slow: context deadline exceeded"),
+		},
+		{
+			name: "status response",
+			err:  NewJSONStatusResponse(nil, http.StatusNotFound),
+			want: NewError(codes.NotFound, http.StatusText(http.StatusNotFound)),
+		},
+		{
+			name: "plain error",
+			err:  errors.New("doh"),
+			want: NewError(codes.Unknown, "doh"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// round trip through a status as the gRPC transport would
+			st := status.Convert(toRPCError(test.err))
+			got := FromRPCError(status.ErrorProto(st.Proto()))
+			if got.Code != test.want.Code || got.Message != test.want.Message {
+				t.Errorf("expected error %s, got %s", test.want, got)
+			}
+			if len(got.Details) != len(test.want.Details) {
+				t.Fatalf("expected %d details, got %d", len(test.want.Details), len(got.Details))
+			}
+			for i := range got.Details {
+				if !proto.Equal(got.Details[i], test.want.Details[i]) {
+					t.Errorf("expected detail %v, got %v", test.want.Details[i], got.Details[i])
+				}
+			}
+		})
+	}
+}
+
+type errorService struct {
+	*streamService
+	err error
+}
+
+func (s *errorService) HTTPEndpoints() map[string]map[string]HTTPEndpoint {
+	return map[string]map[string]HTTPEndpoint{
+		"/error": {
+			"GET": {
+				Endpoint: endpoint.Endpoint(func(ctx context.Context, _ interface{}) (interface{}, error) {
+					return nil, s.err
+				}),
+			},
+		},
+	}
+}
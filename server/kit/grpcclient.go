@@ -0,0 +1,264 @@
+package kit
+
+import (
+	"context"
+	"crypto/tls"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/gizmo/observe"
+	"github.com/kelseyhightower/envconfig"
+	"github.com/pkg/errors"
+	"go.opencensus.io/plugin/ocgrpc"
+	"golang.org/x/oauth2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCClientConfig holds the info required to configure a gRPC client
+// connection with NewGRPCClientConn.
+type GRPCClientConfig struct {
+	// Target is the address of the gRPC server, like "cats.example.com:443".
+	Target string `envconfig:"GRPC_TARGET"`
+
+	// Insecure will disable transport security. Off by default.
+	Insecure bool `envconfig:"GRPC_INSECURE"`
+	// TLSServerName overrides the server name used to verify the server's
+	// certificate.
+	TLSServerName string `envconfig:"GRPC_TLS_SERVER_NAME"`
+	// TLSCAFile is an optional path to a PEM encoded CA bundle used to verify the
+	// server's certificate instead of the system roots.
+	TLSCAFile string `envconfig:"GRPC_TLS_CA"`
+	// TLSCertFile is an optional path to a PEM encoded client certificate for
+	// servers that require one, like a kit server with TLS_CLIENT_CA set.
+	TLSCertFile string `envconfig:"GRPC_TLS_CERT"`
+	// TLSKeyFile is the path to the PEM encoded private key of TLSCertFile.
+	TLSKeyFile string `envconfig:"GRPC_TLS_KEY"`
+
+	// KeepaliveTime is how long the connection can be idle before it is
+	// pinged. Keepalive pings are disabled by default.
+	KeepaliveTime time.Duration `envconfig:"GRPC_KEEPALIVE_TIME"`
+	// KeepaliveTimeout is how long to wait for a keepalive ping to be
+	// acknowledged before the connection is closed. The default is 20s.
+	KeepaliveTimeout time.Duration `envconfig:"GRPC_KEEPALIVE_TIMEOUT"`
+
+	// RetryAttempts is the maximum number of attempts of unary RPCs in
+	// RetryMethods that fail with an Unavailable, ResourceExhausted or Aborted
+	// code. The default is 1, which disables retries.
+	RetryAttempts int `envconfig:"GRPC_RETRY_ATTEMPTS"`
+	// RetryMethods is a comma separated list of the full names of the methods,
+	// like "/cats.Cats/GetCat", that will be retried. As those codes can be
+	// returned after the server has handled the RPC, only idempotent methods
+	// should be listed. Other methods are never retried.
+	RetryMethods []string `envconfig:"GRPC_RETRY_METHODS"`
+	// RetryBackoff is the backoff before the first retry. It doubles after each
+	// attempt, up to a minute. The default is 100ms.
+	RetryBackoff time.Duration `envconfig:"GRPC_RETRY_BACKOFF"`
+
+	// Compression will gzip compress requests and ask for compressed responses.
+	// Off by default.
+	Compression bool `envconfig:"GRPC_COMPRESSION"`
+
+	// PropagateMetadata is a comma separated list of gRPC metadata keys that will
+	// be copied from the incoming request context to outbound RPCs. The request
+	// ID of the context is always propagated.
+	PropagateMetadata []string `envconfig:"GRPC_PROPAGATE_METADATA"`
+}
+
+// LoadGRPCClientConfig will load a GRPCClientConfig from the environment with
+// defaults set. The prefix allows configuring clients of several services, so
+// LoadGRPCClientConfig("CATS") will read CATS_GRPC_TARGET and so on.
+func LoadGRPCClientConfig(prefix string) GRPCClientConfig {
+	var cfg GRPCClientConfig
+	envconfig.MustProcess(prefix, &cfg)
+	if cfg.KeepaliveTimeout.Nanoseconds() == 0 {
+		cfg.KeepaliveTimeout = 20 * time.Second
+	}
+	if cfg.RetryAttempts == 0 {
+		cfg.RetryAttempts = 1
+	}
+	if cfg.RetryBackoff.Nanoseconds() == 0 {
+		cfg.RetryBackoff = 100 * time.Millisecond
+	}
+	return cfg
+}
+
+// GRPCClientOption sets optional behavior of connections created with
+// NewGRPCClientConn.
+type GRPCClientOption func(*grpcClientOptions)
+
+type grpcClientOptions struct {
+	tokens   oauth2.TokenSource
+	dialOpts []grpc.DialOption
+}
+
+// GRPCClientTokenSource will add a token from the given source, like the
+// identity and IAM token sources in the auth/gcp package, to the metadata of
+// every RPC.
+func GRPCClientTokenSource(ts oauth2.TokenSource) GRPCClientOption {
+	return func(o *grpcClientOptions) {
+		o.tokens = ts
+	}
+}
+
+// GRPCClientDialOptions will add the given options when dialing the server.
+// Interceptors added here are called after the kit interceptors.
+func GRPCClientDialOptions(opts ...grpc.DialOption) GRPCClientOption {
+	return func(o *grpcClientOptions) {
+		o.dialOpts = append(o.dialOpts, opts...)
+	}
+}
+
+// NewGRPCClientConn will create a client connection to the server at cfg.Target.
+// RPCs made over the connection are traced and measured with OpenCensus, carry
+// the request ID and the metadata from cfg.PropagateMetadata of the incoming
+// request and, depending on the config and options, are authenticated and
+// retried. Like grpc.Dial, it does not wait for the connection to be
+// established.
+func NewGRPCClientConn(ctx context.Context, cfg GRPCClientConfig, opts ...GRPCClientOption) (*grpc.ClientConn, error) {
+	if cfg.Target == "" {
+		return nil, errors.New("a target is required")
+	}
+	var o grpcClientOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	dopts := []grpc.DialOption{grpc.WithStatsHandler(&ocgrpc.ClientHandler{})}
+	if cfg.Insecure {
+		dopts = append(dopts, grpc.WithInsecure())
+	} else {
+		tcfg, err := newClientTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		dopts = append(dopts, grpc.WithTransportCredentials(credentials.NewTLS(tcfg)))
+	}
+	if o.tokens != nil {
+		dopts = append(dopts, grpc.WithPerRPCCredentials(
+			tokenCredentials{ts: o.tokens, secure: !cfg.Insecure}))
+	}
+	if cfg.Compression {
+		dopts = append(dopts, grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
+	}
+	if cfg.KeepaliveTime > 0 {
+		dopts = append(dopts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:    cfg.KeepaliveTime,
+			Timeout: cfg.KeepaliveTimeout,
+		}))
+	}
+
+	unary := []grpc.UnaryClientInterceptor{propagateUnary(cfg.PropagateMetadata)}
+	if cfg.RetryAttempts > 1 && len(cfg.RetryMethods) > 0 {
+		unary = append(unary, retryUnary(cfg.RetryMethods, cfg.RetryAttempts, cfg.RetryBackoff))
+	}
+	dopts = append(dopts,
+		grpc.WithChainUnaryInterceptor(unary...),
+		grpc.WithChainStreamInterceptor(propagateStream(cfg.PropagateMetadata)),
+	)
+
+	cc, err := grpc.DialContext(ctx, cfg.Target, append(dopts, o.dialOpts...)...)
+	return cc, errors.Wrap(err, "unable to dial gRPC server")
+}
+
+func newClientTLSConfig(cfg GRPCClientConfig) (*tls.Config, error) {
+	tcfg := &tls.Config{
+		ServerName: cfg.TLSServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if cfg.TLSCAFile != "" {
+		pool, err := readCertPool(cfg.TLSCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid server CA")
+		}
+		tcfg.RootCAs = pool
+	}
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to load client certificate")
+		}
+		tcfg.Certificates = []tls.Certificate{cert}
+	}
+	return tcfg, nil
+}
+
+// tokenCredentials are credentials.PerRPCCredentials from an oauth2.TokenSource
+// that can be used over insecure connections for local development.
+type tokenCredentials struct {
+	ts     oauth2.TokenSource
+	secure bool
+}
+
+func (c tokenCredentials) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	tok, err := c.ts.Token()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get auth token")
+	}
+	return map[string]string{"authorization": tok.Type() + " " + tok.AccessToken}, nil
+}
+
+func (c tokenCredentials) RequireTransportSecurity() bool {
+	return c.secure
+}
+
+// outgoingContext will add the request ID and the given keys of the incoming
+// metadata to the outgoing metadata.
+func outgoingContext(ctx context.Context, keys []string) context.Context {
+	var kv []string
+	in, _ := metadata.FromIncomingContext(ctx)
+	out, _ := metadata.FromOutgoingContext(ctx)
+	if id := observe.RequestID(ctx); id != "" && len(out.Get(observe.RequestIDKey)) == 0 {
+		kv = append(kv, observe.RequestIDKey, id)
+	}
+	for _, k := range keys {
+		k = strings.ToLower(k)
+		if k == observe.RequestIDKey || len(out.Get(k)) > 0 {
+			continue
+		}
+		for _, v := range in.Get(k) {
+			kv = append(kv, k, v)
+		}
+	}
+	if len(kv) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+func propagateUnary(keys []string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(outgoingContext(ctx, keys), method, req, reply, cc, opts...)
+	}
+}
+
+func propagateStream(keys []string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(outgoingContext(ctx, keys), desc, cc, method, opts...)
+	}
+}
+
+// retryUnary will retry unary RPCs of the given methods that fail with a
+// transient code.
+func retryUnary(methods []string, attempts int, backoff time.Duration) grpc.UnaryClientInterceptor {
+	retry := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		retry[strings.TrimSpace(m)] = true
+	}
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if !retry[method] {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		for i := 1; ; i++ {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil || i >= attempts || !isRetryableCode(status.Code(err)) ||
+				!waitBackoff(ctx, backoff, i) {
+				return err
+			}
+		}
+	}
+}
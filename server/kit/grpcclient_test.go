@@ -0,0 +1,101 @@
+package kit_test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"golang.org/x/oauth2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/NYTimes/gizmo/observe"
+	"github.com/NYTimes/gizmo/server/kit"
+)
+
+func TestGRPCClientConn(t *testing.T) {
+	var calls int32
+	gsvr := grpc.NewServer(grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		if got := md.Get("x-request-id"); len(got) != 1 || got[0] != "abc123" {
+			t.Errorf("expected the request ID to be propagated, got %v", got)
+		}
+		if got := md.Get("x-tenant"); len(got) != 1 || got[0] != "nyt" {
+			t.Errorf("expected the tenant to be propagated, got %v", got)
+		}
+		if got := md.Get("authorization"); len(got) != 1 || got[0] != "Bearer t0k3n" {
+			t.Errorf("expected the auth token, got %v", got)
+		}
+		ts, _ := grpc.ServerTransportStreamFromContext(stream.Context()).(interface{ RecvCompress() string })
+		if ts == nil || ts.RecvCompress() != "gzip" {
+			t.Error("expected a gzip compressed request")
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return status.Error(codes.Unavailable, "try again")
+		}
+		var in wrappers.StringValue
+		if err := stream.RecvMsg(&in); err != nil {
+			return err
+		}
+		return stream.SendMsg(&in)
+	}))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go gsvr.Serve(lis)
+	defer gsvr.Stop()
+
+	cfg := kit.LoadGRPCClientConfig("")
+	cfg.Target = lis.Addr().String()
+	cfg.Insecure = true
+	cfg.RetryAttempts = 3
+	cfg.RetryMethods = []string{"/kit_test.Echo/Echo"}
+	cfg.RetryBackoff = 0
+	cfg.PropagateMetadata = []string{"X-Tenant"}
+	cfg.Compression = true
+	cc, err := kit.NewGRPCClientConn(context.Background(), cfg,
+		kit.GRPCClientTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "t0k3n"})))
+	if err != nil {
+		t.Fatalf("unable to create connection: %s", err)
+	}
+	defer cc.Close()
+
+	// the context of an inbound request
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant", "nyt"))
+	ctx = observe.WithRequestID(ctx, "abc123")
+	var out wrappers.StringValue
+	if err := cc.Invoke(ctx, "/kit_test.Echo/Echo", &wrappers.StringValue{Value: "hi"}, &out); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if out.Value != "hi" {
+		t.Errorf("expected echoed value, got %q", out.Value)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+
+	// methods that are not listed are not retried
+	atomic.StoreInt32(&calls, 0)
+	err = cc.Invoke(ctx, "/kit_test.Echo/Create", &wrappers.StringValue{Value: "hi"}, &out)
+	if status.Code(err) != codes.Unavailable || calls != 1 {
+		t.Errorf("expected a single attempt of an unlisted method, got %d and %v", calls, err)
+	}
+
+	cfg.RetryAttempts = 1
+	atomic.StoreInt32(&calls, 0)
+	cc2, err := kit.NewGRPCClientConn(context.Background(), cfg,
+		kit.GRPCClientTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "t0k3n"})))
+	if err != nil {
+		t.Fatalf("unable to create connection: %s", err)
+	}
+	defer cc2.Close()
+	err = cc2.Invoke(ctx, "/kit_test.Echo/Echo", &wrappers.StringValue{Value: "hi"}, &out)
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("expected unavailable error without retries, got %v", err)
+	}
+}
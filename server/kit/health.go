@@ -0,0 +1,173 @@
+package kit
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthCheck checks a single dependency of a service, like a database or a
+// pubsub connection, and returns an error if it is unavailable. The given context
+// will be canceled once the check exceeds the configured HealthCheckTimeout.
+type HealthCheck func(ctx context.Context) error
+
+// HealthChecker is an optional interface a Service can implement to register
+// dependency checks with the kit server's readiness endpoint.
+//
+// The readiness endpoint, served on ReadinessPath, runs all checks concurrently
+// and responds with a JSON report and a 200 status if they all pass or a 503
+// status if any fail. Results are cached for HealthCheckCacheTTL to protect the
+// dependencies from aggressive probes. Readiness will start failing as soon as
+// the server begins to shut down.
+//
+// The liveness endpoint, served on HealthCheckPath, does not run these checks
+// so an unavailable dependency will not cause the service to be restarted.
+type HealthChecker interface {
+	// HealthChecks returns the checks to run keyed by the name of the dependency.
+	// For example:
+	//
+	//    return map[string]kit.HealthCheck{
+	//        "mysql": kit.PingHealthCheck(s.db),
+	//    }
+	HealthChecks() map[string]HealthCheck
+}
+
+// Pinger is implemented by clients that can check their connection, like
+// *sql.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// PingHealthCheck will return a HealthCheck that pings the given client.
+func PingHealthCheck(p Pinger) HealthCheck {
+	return p.PingContext
+}
+
+// HealthReport is the JSON response of the readiness endpoint.
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks,omitempty"`
+}
+
+// HealthCheckResult is the outcome of a single HealthCheck.
+type HealthCheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+const (
+	healthStatusOK           = "ok"
+	healthStatusFailing      = "failing"
+	healthStatusShuttingDown = "shutting down"
+)
+
+// StatusCode implements httptransport.StatusCoder and will return 503 unless
+// the report is healthy.
+func (r *HealthReport) StatusCode() int {
+	if r.Status != healthStatusOK {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// readiness runs and caches the health checks of a service.
+type readiness struct {
+	checks  map[string]HealthCheck
+	timeout time.Duration
+	ttl     time.Duration
+
+	shuttingDown chan struct{}
+	shutdownOnce sync.Once
+
+	mu      sync.Mutex
+	report  *HealthReport
+	checked time.Time
+}
+
+func newReadiness(cfg Config, checks map[string]HealthCheck) *readiness {
+	return &readiness{
+		checks:       checks,
+		timeout:      cfg.HealthCheckTimeout,
+		ttl:          cfg.HealthCheckCacheTTL,
+		shuttingDown: make(chan struct{}),
+	}
+}
+
+// shutdown will fail all future readiness checks.
+func (r *readiness) shutdown() {
+	r.shutdownOnce.Do(func() { close(r.shuttingDown) })
+}
+
+func (r *readiness) endpoint(context.Context, interface{}) (interface{}, error) {
+	select {
+	case <-r.shuttingDown:
+		return &HealthReport{Status: healthStatusShuttingDown}, nil
+	default:
+	}
+
+	// checks are serialized so concurrent probes share the cached report
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.report != nil && time.Since(r.checked) < r.ttl {
+		return r.report, nil
+	}
+	r.report = r.run()
+	r.checked = time.Now()
+	return r.report, nil
+}
+
+// run will run the checks. They are not bound to the probe's request, as
+// their report is shared with other probes, so a cancelled probe cannot cache
+// a failure.
+func (r *readiness) run() *HealthReport {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	report := &HealthReport{
+		Status: healthStatusOK,
+		Checks: make(map[string]HealthCheckResult, len(r.checks)),
+	}
+	for name, check := range r.checks {
+		wg.Add(1)
+		go func(name string, check HealthCheck) {
+			defer wg.Done()
+			start := time.Now()
+			err := runCheck(ctx, check)
+			res := HealthCheckResult{
+				Status:   healthStatusOK,
+				Duration: time.Since(start).String(),
+			}
+			if err != nil {
+				res.Status, res.Error = healthStatusFailing, err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = res
+			if err != nil {
+				report.Status = healthStatusFailing
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return report
+}
+
+// runCheck will run the check and return early if it ignores the context's
+// deadline.
+func runCheck(ctx context.Context, check HealthCheck) error {
+	errs := make(chan error, 1)
+	go func() { errs <- check(ctx) }()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,138 @@
+package kit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKitServerReadiness(t *testing.T) {
+	os.Setenv("GIZMO_HEALTH_CHECK_TIMEOUT", "50ms")
+	os.Setenv("GIZMO_HEALTH_CHECK_CACHE_TTL", "1h")
+	defer os.Unsetenv("GIZMO_HEALTH_CHECK_TIMEOUT")
+	defer os.Unsetenv("GIZMO_HEALTH_CHECK_CACHE_TTL")
+
+	var dbCalls int32
+	svc := &healthService{
+		streamService: &streamService{},
+		checks: map[string]HealthCheck{
+			"db": func(context.Context) error {
+				atomic.AddInt32(&dbCalls, 1)
+				return nil
+			},
+			"pubsub": func(context.Context) error { return errors.New("not connected") },
+			"slow": func(context.Context) error {
+				// ignores the context, should still time out
+				time.Sleep(time.Second)
+				return nil
+			},
+		},
+	}
+	svr := NewServer(svc)
+
+	probe := func(path string) (int, HealthReport) {
+		w := httptest.NewRecorder()
+		svr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var report HealthReport
+		json.Unmarshal(w.Body.Bytes(), &report)
+		return w.Code, report
+	}
+
+	code, report := probe("/readyz")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("expected status code of 503, got %d", code)
+	}
+	want := map[string]HealthCheckResult{
+		"db":     {Status: "ok"},
+		"pubsub": {Status: "failing", Error: "not connected"},
+		"slow":   {Status: "failing", Error: context.DeadlineExceeded.Error()},
+	}
+	if report.Status != "failing" || len(report.Checks) != len(want) {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	for name, w := range want {
+		got := report.Checks[name]
+		if got.Status != w.Status || got.Error != w.Error {
+			t.Errorf("expected %s check to be %+v, got %+v", name, w, got)
+		}
+	}
+
+	// results are cached
+	probe("/readyz")
+	if calls := atomic.LoadInt32(&dbCalls); calls != 1 {
+		t.Errorf("expected checks to run once, ran %d times", calls)
+	}
+
+	// liveness does not run the checks
+	if code, _ := probe("/healthz"); code != http.StatusOK {
+		t.Errorf("expected liveness status code of 200, got %d", code)
+	}
+}
+
+func TestReadinessCancelledProbe(t *testing.T) {
+	r := newReadiness(Config{HealthCheckTimeout: time.Second, HealthCheckCacheTTL: time.Hour},
+		map[string]HealthCheck{
+			"db": func(ctx context.Context) error { return ctx.Err() },
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, _ := r.endpoint(ctx, nil)
+	if report := res.(*HealthReport); report.Status != "ok" {
+		t.Errorf("expected the checks to ignore the probe's cancellation, got %+v", report)
+	}
+}
+
+func TestKitServerReadinessShutdown(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := lis.Addr().(*net.TCPAddr).Port
+	lis.Close()
+
+	os.Setenv("GIZMO_SINGLE_PORT", "true")
+	os.Setenv("HTTP_ADDR", "127.0.0.1")
+	os.Setenv("HTTP_PORT", strconv.Itoa(port))
+	defer os.Unsetenv("GIZMO_SINGLE_PORT")
+	defer os.Unsetenv("HTTP_ADDR")
+	defer os.Unsetenv("HTTP_PORT")
+
+	svr := NewServer(&healthService{streamService: &streamService{}})
+	if err := svr.Start(context.Background()); err != nil {
+		t.Fatalf("unable to start server: %s", err)
+	}
+
+	probe := func() int {
+		w := httptest.NewRecorder()
+		svr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return w.Code
+	}
+
+	if code := probe(); code != http.StatusOK {
+		t.Errorf("expected status code of 200 before shutdown, got %d", code)
+	}
+	if err := svr.Stop(context.Background()); err != nil {
+		t.Fatalf("unexpected error on shutdown: %s", err)
+	}
+	if code := probe(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected status code of 503 after shutdown, got %d", code)
+	}
+}
+
+type healthService struct {
+	*streamService
+	checks map[string]HealthCheck
+}
+
+func (s *healthService) HealthChecks() map[string]HealthCheck {
+	return s.checks
+}
@@ -0,0 +1,388 @@
+package kit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/gizmo/observe"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"go.opencensus.io/plugin/ochttp"
+	"golang.org/x/oauth2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// HTTPClientEndpoint is the client side counterpart of an HTTPEndpoint. It
+// describes how to call an endpoint of a kit service over HTTP and can be turned
+// into an endpoint.Endpoint with NewHTTPClientEndpoint:
+//
+//	getCat, err := kit.NewHTTPClientEndpoint("https://cats.example.com", kit.HTTPClientEndpoint{
+//		Method: http.MethodGet,
+//		Path:   "/svc/cat/{name:[a-zA-Z]+}",
+//		Vars: func(req interface{}) map[string]string {
+//			return map[string]string{"name": req.(*GetCatRequest).Name}
+//		},
+//		Decoder: kit.NegotiatedResponseDecoder(func() interface{} { return &Cat{} }),
+//	}, kit.HTTPClientRetry(3, 100*time.Millisecond))
+type HTTPClientEndpoint struct {
+	// Method is the HTTP method of the endpoint.
+	Method string
+	// Path is the route template of the endpoint, like "/cats/{name}". The same
+	// templates used to register an HTTPEndpoint, including patterns like
+	// "{id:[0-9]+}", are supported.
+	Path string
+	// Vars returns the values of the route variables in Path for a request. It is
+	// required if Path has any variables.
+	Vars func(request interface{}) map[string]string
+
+	// Encoder encodes the request. If nil, requests will be encoded with
+	// EncodeJSONRequest for methods with a body and sent without one otherwise.
+	Encoder httptransport.EncodeRequestFunc
+	// Decoder decodes the response. It is required. NegotiatedResponseDecoder
+	// will decode JSON and Protobuf responses as well as errors.
+	Decoder httptransport.DecodeResponseFunc
+	// Options are any go-kit client options for the endpoint.
+	Options []httptransport.ClientOption
+}
+
+// HTTPClientOption sets optional behavior of the endpoints returned by
+// NewHTTPClientEndpoint.
+type HTTPClientOption func(*httpClientOptions)
+
+type httpClientOptions struct {
+	client   *http.Client
+	tokens   oauth2.TokenSource
+	attempts int
+	backoff  time.Duration
+	breaker  *CircuitBreaker
+}
+
+// HTTPClientHTTPClient will use the given client to make requests instead of
+// http.DefaultClient. Its transport will be wrapped to add OpenCensus tracing.
+func HTTPClientHTTPClient(c *http.Client) HTTPClientOption {
+	return func(o *httpClientOptions) {
+		o.client = c
+	}
+}
+
+// HTTPClientTokenSource will add a token from the given source, like the ones
+// from the auth/gcp package, to the Authorization header of every request.
+func HTTPClientTokenSource(ts oauth2.TokenSource) HTTPClientOption {
+	return func(o *httpClientOptions) {
+		o.tokens = ts
+	}
+}
+
+// HTTPClientRetry will retry requests of idempotent methods up to the given number
+// of attempts when they fail with a network error or an Unavailable,
+// ResourceExhausted or Aborted Error. The backoff between attempts starts at the
+// given duration and doubles after each attempt, up to a minute.
+func HTTPClientRetry(attempts int, backoff time.Duration) HTTPClientOption {
+	return func(o *httpClientOptions) {
+		o.attempts, o.backoff = attempts, backoff
+	}
+}
+
+// HTTPClientCircuitBreaker will reject requests with ErrCircuitOpen while the
+// given CircuitBreaker is open. A CircuitBreaker can be shared by the endpoints
+// of a service.
+func HTTPClientCircuitBreaker(cb *CircuitBreaker) HTTPClientOption {
+	return func(o *httpClientOptions) {
+		o.breaker = cb
+	}
+}
+
+// NewHTTPClientEndpoint will return an endpoint.Endpoint that calls the given
+// HTTPClientEndpoint on the service at baseURL. Requests are traced with
+// OpenCensus, carry the request ID of the context and, depending on the
+// options, are authenticated, retried and guarded by a circuit breaker.
+func NewHTTPClientEndpoint(baseURL string, e HTTPClientEndpoint, opts ...HTTPClientOption) (endpoint.Endpoint, error) {
+	if e.Decoder == nil {
+		return nil, errors.New("a Decoder is required")
+	}
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid base URL")
+	}
+	var o httpClientOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	enc := e.Encoder
+	if enc == nil {
+		enc = noBodyEncoder
+		switch e.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+			enc = EncodeJSONRequest
+		}
+	}
+
+	client := http.Client{}
+	if o.client != nil {
+		client = *o.client
+	}
+	client.Transport = &ochttp.Transport{
+		Base:           client.Transport,
+		FormatSpanName: func(*http.Request) string { return e.Method + " " + e.Path },
+	}
+
+	ep := httptransport.NewClient(e.Method, base,
+		routeEncoder(base.Path, e, o.tokens, enc), e.Decoder,
+		append([]httptransport.ClientOption{httptransport.SetClient(&client)},
+			e.Options...)...).Endpoint()
+
+	if o.breaker != nil {
+		ep = o.breaker.Middleware(ep)
+	}
+	if o.attempts > 1 && isIdempotent(e.Method) {
+		ep = retryEndpoint(ep, o.attempts, o.backoff)
+	}
+	return ep, nil
+}
+
+// routeEncoder will set the path of the request from the route template and
+// add the Authorization header before encoding the request.
+func routeEncoder(basePath string, e HTTPClientEndpoint, ts oauth2.TokenSource, enc httptransport.EncodeRequestFunc) httptransport.EncodeRequestFunc {
+	return func(ctx context.Context, r *http.Request, req interface{}) error {
+		var vars map[string]string
+		if e.Vars != nil {
+			vars = e.Vars(req)
+		}
+		path, err := expandRoute(e.Path, vars)
+		if err != nil {
+			return err
+		}
+		r.URL.RawPath = basePath + path
+		if r.URL.Path, err = url.PathUnescape(r.URL.RawPath); err != nil {
+			return err
+		}
+
+		if ts != nil {
+			tok, err := ts.Token()
+			if err != nil {
+				return errors.Wrap(err, "unable to get auth token")
+			}
+			tok.SetAuthHeader(r)
+		}
+		if id := observe.RequestID(ctx); id != "" {
+			r.Header.Set(observe.RequestIDKey, id)
+		}
+		return enc(ctx, r, req)
+	}
+}
+
+// expandRoute will replace the variables in the route template with the escaped
+// values.
+func expandRoute(route string, vars map[string]string) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.Index(route, "{")
+		if start < 0 {
+			b.WriteString(route)
+			return b.String(), nil
+		}
+		end := strings.Index(route[start:], "}")
+		if end < 0 {
+			return "", errors.Errorf("invalid route template %q", route)
+		}
+		end += start
+
+		name := route[start+1 : end]
+		if i := strings.Index(name, ":"); i >= 0 {
+			// patterns may contain braces, like {id:[0-9]{4}}
+			for strings.Count(route[start:end+1], "{") > strings.Count(route[start:end+1], "}") {
+				next := strings.Index(route[end+1:], "}")
+				if next < 0 {
+					return "", errors.Errorf("invalid route template %q", route)
+				}
+				end += next + 1
+			}
+			name = name[:i]
+		}
+		val, ok := vars[name]
+		if !ok {
+			return "", errors.Errorf("missing value for route variable %q", name)
+		}
+		b.WriteString(route[:start])
+		b.WriteString(url.PathEscape(val))
+		route = route[end+1:]
+	}
+}
+
+func noBodyEncoder(context.Context, *http.Request, interface{}) error {
+	return nil
+}
+
+// EncodeJSONRequest is an httptransport.EncodeRequestFunc that serializes the
+// request as JSON. proto.Message requests are serialized with protojson.
+func EncodeJSONRequest(_ context.Context, r *http.Request, req interface{}) error {
+	var (
+		b   []byte
+		err error
+	)
+	if pm, ok := req.(proto.Message); ok {
+		b, err = protojson.Marshal(proto.MessageV2(pm))
+	} else {
+		b, err = json.Marshal(req)
+	}
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	setRequestBody(r, b)
+	return nil
+}
+
+// EncodeProtoRequest is an httptransport.EncodeRequestFunc that serializes the
+// request as Protobuf and asks for a Protobuf response.
+func EncodeProtoRequest(_ context.Context, r *http.Request, preq interface{}) error {
+	req, ok := preq.(proto.Message)
+	if !ok {
+		return errors.New("request does not implement proto.Message")
+	}
+	b, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/x-protobuf")
+	r.Header.Set("Accept", "application/x-protobuf")
+	setRequestBody(r, b)
+	return nil
+}
+
+func setRequestBody(r *http.Request, b []byte) {
+	r.ContentLength = int64(len(b))
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+}
+
+// NegotiatedResponseDecoder returns an httptransport.DecodeResponseFunc that is
+// the client side counterpart of NegotiatedDecoder. Error responses are decoded
+// with DecodeErrorResponse. Successful responses are decoded into the value
+// returned by newResponse based on their Content-Type: Protobuf bodies require
+// the value to be a proto.Message, JSON bodies are decoded with protojson if the
+// value is a proto.Message and with encoding/json otherwise.
+func NegotiatedResponseDecoder(newResponse func() interface{}) httptransport.DecodeResponseFunc {
+	return func(_ context.Context, r *http.Response) (interface{}, error) {
+		if err := DecodeErrorResponse(r); err != nil {
+			return nil, err
+		}
+		res := newResponse()
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read response body")
+		}
+		if len(b) == 0 {
+			return res, nil
+		}
+
+		pm, isProto := res.(proto.Message)
+		switch {
+		case isProtoContentType(r.Header.Get("Content-Type")):
+			if !isProto {
+				return nil, errors.New("response does not implement proto.Message")
+			}
+			err = proto.Unmarshal(b, pm)
+		case isProto:
+			err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(b, proto.MessageV2(pm))
+		default:
+			err = json.Unmarshal(b, res)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to decode response body")
+		}
+		return res, nil
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryEndpoint will retry failed requests with an exponential backoff.
+func retryEndpoint(next endpoint.Endpoint, attempts int, backoff time.Duration) endpoint.Endpoint {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		for i := 1; ; i++ {
+			res, err := next(ctx, req)
+			if err == nil || i >= attempts || !isRetryable(err) || !waitBackoff(ctx, backoff, i) {
+				return res, err
+			}
+		}
+	}
+}
+
+// maxRetryBackoff caps the backoff between attempts.
+const maxRetryBackoff = time.Minute
+
+// waitBackoff will wait before the next attempt, doubling the backoff after
+// each attempt up to maxRetryBackoff. It returns false if the context is done
+// first.
+func waitBackoff(ctx context.Context, backoff time.Duration, attempt int) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if attempt > 30 {
+		attempt = 30
+	}
+	max := backoff
+	if max > maxRetryBackoff>>uint(attempt-1) {
+		max = maxRetryBackoff
+	} else {
+		max <<= uint(attempt - 1)
+	}
+	if max < 0 {
+		max = 0
+	}
+	// full jitter to spread out retries of concurrent requests
+	wait := time.Duration(rand.Int63n(int64(max) + 1))
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// isRetryable returns true for network errors and Errors signaling a transient
+// condition.
+func isRetryable(err error) bool {
+	if err == ErrCircuitOpen {
+		return false
+	}
+	switch e := errors.Cause(err).(type) {
+	case *Error:
+		return isRetryableCode(e.Code)
+	case net.Error:
+		return true
+	}
+	return false
+}
+
+func isRetryableCode(c codes.Code) bool {
+	switch c {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
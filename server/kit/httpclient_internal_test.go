@@ -0,0 +1,21 @@
+package kit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWaitBackoffLimits(t *testing.T) {
+	// large backoffs and attempt counts are capped instead of overflowing
+	for _, attempt := range []int{1, 40, 64, 1000} {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		if waitBackoff(ctx, 24*time.Hour, attempt) {
+			t.Errorf("expected attempt %d to wait past the context deadline", attempt)
+		}
+		cancel()
+	}
+	if !waitBackoff(context.Background(), 0, 100) {
+		t.Error("expected no wait without a backoff")
+	}
+}
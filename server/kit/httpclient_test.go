@@ -0,0 +1,196 @@
+package kit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/observe"
+	"github.com/NYTimes/gizmo/server/kit"
+	"github.com/NYTimes/gizmo/server/kit/kittest"
+	"golang.org/x/oauth2"
+	"google.golang.org/grpc/codes"
+)
+
+func TestHTTPClientEndpoint(t *testing.T) {
+	svr := kittest.NewServer(&server{})
+	defer svr.Close()
+
+	getCat, err := kit.NewHTTPClientEndpoint(svr.URL, kit.HTTPClientEndpoint{
+		Method: http.MethodGet,
+		Path:   "/svc/cat/{name:[a-zA-Z]+}",
+		Vars: func(req interface{}) map[string]string {
+			return map[string]string{"name": req.(*GetCatNameRequest).Name}
+		},
+		Decoder: kit.NegotiatedResponseDecoder(func() interface{} { return &Cat{} }),
+	}, kit.HTTPClientHTTPClient(svr.Client))
+	if err != nil {
+		t.Fatalf("unable to create endpoint: %s", err)
+	}
+	res, err := getCat(context.Background(), &GetCatNameRequest{Name: "ziggy"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(res, testCat) {
+		t.Errorf("expected cat: %#v, got %#v", testCat, res)
+	}
+
+	getErr, err := kit.NewHTTPClientEndpoint(svr.URL, kit.HTTPClientEndpoint{
+		Method:  http.MethodGet,
+		Path:    "/svc/error",
+		Decoder: kit.NegotiatedResponseDecoder(func() interface{} { return &Cat{} }),
+	}, kit.HTTPClientHTTPClient(svr.Client))
+	if err != nil {
+		t.Fatalf("unable to create endpoint: %s", err)
+	}
+	_, err = getErr(context.Background(), nil)
+	if kerr, ok := err.(*kit.Error); !ok || kerr.Code == codes.OK {
+		t.Errorf("expected a *kit.Error, got %#v", err)
+	}
+}
+
+func TestHTTPClientEndpointRetry(t *testing.T) {
+	var calls int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/cat/Mr%20Ziggy" {
+			t.Errorf("unexpected path %q", r.URL.EscapedPath())
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer t0k3n" {
+			t.Errorf("expected the auth token, got %q", got)
+		}
+		if got := r.Header.Get("X-Request-Id"); got != "abc123" {
+			t.Errorf("expected the request ID to be propagated, got %q", got)
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"Name":"Mr Ziggy","Age":12}`))
+	}))
+	defer svr.Close()
+
+	getCat, err := kit.NewHTTPClientEndpoint(svr.URL+"/api", kit.HTTPClientEndpoint{
+		Method: http.MethodGet,
+		Path:   "/cat/{name}",
+		Vars: func(req interface{}) map[string]string {
+			return map[string]string{"name": req.(string)}
+		},
+		Decoder: kit.NegotiatedResponseDecoder(func() interface{} { return &Cat{} }),
+	},
+		kit.HTTPClientTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "t0k3n"})),
+		kit.HTTPClientRetry(3, time.Millisecond))
+	if err != nil {
+		t.Fatalf("unable to create endpoint: %s", err)
+	}
+	res, err := getCat(observe.WithRequestID(context.Background(), "abc123"), "Mr Ziggy")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cat := res.(*Cat); cat.Name != "Mr Ziggy" || cat.Age != 12 {
+		t.Errorf("unexpected cat: %#v", cat)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var (
+		calls int
+		fail  = true
+	)
+	ep := kit.NewCircuitBreaker(2, 50*time.Millisecond).Middleware(
+		func(context.Context, interface{}) (interface{}, error) {
+			calls++
+			if fail {
+				return nil, errors.New("connection refused")
+			}
+			return "ok", nil
+		})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		ep(ctx, nil)
+	}
+	if _, err := ep(ctx, nil); err != kit.ErrCircuitOpen {
+		t.Errorf("expected the circuit to be open, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls before opening, got %d", calls)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	fail = false
+	if res, err := ep(ctx, nil); err != nil || res != "ok" {
+		t.Errorf("expected the probe to succeed, got %v, %v", res, err)
+	}
+	if _, err := ep(ctx, nil); err != nil {
+		t.Errorf("expected the circuit to be closed, got %v", err)
+	}
+}
+
+func TestCircuitBreakerCanceledProbe(t *testing.T) {
+	var err error
+	ep := kit.NewCircuitBreaker(2, 10*time.Millisecond).Middleware(
+		func(context.Context, interface{}) (interface{}, error) {
+			return nil, err
+		})
+	ctx := context.Background()
+
+	err = errors.New("connection refused")
+	ep(ctx, nil)
+	ep(ctx, nil)
+	time.Sleep(20 * time.Millisecond)
+
+	// a canceled probe does not close the breaker
+	err = context.Canceled
+	ep(ctx, nil)
+	err = errors.New("connection refused")
+	if _, got := ep(ctx, nil); got == kit.ErrCircuitOpen {
+		t.Fatal("expected the next call to probe the dependency")
+	}
+	if _, got := ep(ctx, nil); got != kit.ErrCircuitOpen {
+		t.Errorf("expected the failed probe to reopen the circuit, got %v", got)
+	}
+}
+
+func TestCircuitBreakerPanic(t *testing.T) {
+	var panics bool
+	ep := kit.NewCircuitBreaker(1, 10*time.Millisecond).Middleware(
+		func(context.Context, interface{}) (interface{}, error) {
+			if panics {
+				panic("boom")
+			}
+			return nil, errors.New("connection refused")
+		})
+	call := func() (err error) {
+		defer func() {
+			if recover() != nil {
+				err = errors.New("panicked")
+			}
+		}()
+		_, err = ep(context.Background(), nil)
+		return err
+	}
+
+	call()
+	time.Sleep(20 * time.Millisecond)
+	// the probe panics and reopens the breaker
+	panics = true
+	if err := call(); err == nil || err.Error() != "panicked" {
+		t.Fatalf("expected the probe to panic, got %v", err)
+	}
+	if err := call(); err != kit.ErrCircuitOpen {
+		t.Errorf("expected the circuit to be open after the panic, got %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := call(); err == kit.ErrCircuitOpen {
+		t.Error("expected another probe after the cooldown")
+	}
+}
@@ -3,6 +3,7 @@ package kit
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	stdlog "log"
 	"net"
@@ -10,6 +11,8 @@ import (
 	"net/http/pprof"
 	"runtime/debug"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"cloud.google.com/go/errorreporting"
 	"cloud.google.com/go/profiler"
@@ -20,17 +23,25 @@ import (
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/pkg/errors"
+	"github.com/soheilhy/cmux"
 	"go.opencensus.io/plugin/ocgrpc"
 	"go.opencensus.io/plugin/ochttp"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/trace"
 	"go.opencensus.io/trace/propagation"
 	ocontext "golang.org/x/net/context"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 // Server encapsulates all logic for registering and running a gizmo kit server.
 type Server struct {
+	// inFlight is the number of requests being served. It is accessed
+	// atomically and kept first for 64-bit alignment.
+	inFlight int64
+
 	logger   log.Logger
 	logClose func() error
 	ocFlush  func()
@@ -46,10 +57,32 @@ type Server struct {
 	svr  *http.Server
 	gsvr *grpc.Server
 
+	tlsConfig *tls.Config
+	// rpcOverHTTP is set when gRPC requests are served by svr's handler.
+	rpcOverHTTP bool
+
 	handler http.Handler
 
+	ready *readiness
+
+	accessLog *accessLogger
+	logLevel  *levelFilter
+
+	// adminSvr serves operational endpoints when enabled.
+	adminSvr  *http.Server
+	adminAddr string
+
+	// started is set atomically once Start is called.
+	started int32
+	// addr and rpcAddr are the bound listener addresses.
+	addr    string
+	rpcAddr string
+
 	// exit chan for graceful shutdown
-	exit chan chan error
+	exit chan stopRequest
+	// stopped is closed with stopErr set once the server has shut down.
+	stopped chan struct{}
+	stopErr error
 }
 
 type contextKey int
@@ -65,16 +98,30 @@ const (
 	// and inject the value into the request context. If in the App Engine environment
 	// this will be used to enable combined access and application logs.
 	ContextKeyCloudTraceContext
+
+	// ContextKeyPeerCertificate is a context key for storing and retrieving the
+	// verified *x509.Certificate of the client. It is only set if the server is
+	// configured to verify client certificates. See PeerCertificate.
+	ContextKeyPeerCertificate
+
+	// key for the access log entry of a request
+	accessLogKey
 )
 
 // NewServer will create a new kit server for the given Service.
 //
 // Generally, users should only use the 'Run' function to start a server and use this
-// function within tests so they may call ServeHTTP.
+// function within tests so they may call ServeHTTP or Start and Stop.
 func NewServer(svc Service) *Server {
 	// load config from environment with defaults set
-	cfg := loadConfig()
+	return NewServerWithConfig(svc, LoadConfig())
+}
 
+// NewServerWithConfig will create a new kit server for the given Service using the
+// given Config as is. Use LoadConfig to start from the environment and defaults.
+// Setting HTTPPort or RPCPort to 0 will bind to an ephemeral port, which can be
+// found with Addr and RPCAddr once the server is started.
+func NewServerWithConfig(svc Service, cfg Config) *Server {
 	ropts := svc.HTTPRouterOptions()
 	// default the router if none set
 	if len(ropts) == 0 {
@@ -87,7 +134,11 @@ func NewServer(svc Service) *Server {
 
 	ctx := context.Background()
 
-	lg, logClose, err := NewLogger(ctx, "")
+	var backend log.Logger
+	if ls, ok := svc.(LoggingService); ok {
+		backend = ls.LogBackend()
+	}
+	lg, logClose, err := newLogger(ctx, "", cfg, backend)
 	if err != nil {
 		stdlog.Fatalf("unable to start up logger: %s", err)
 	}
@@ -102,9 +153,11 @@ func NewServer(svc Service) *Server {
 		lg.Log("error", err, "message", "exporter client encountered an error")
 	}
 	ocFlush := func() {}
+	var gcp bool
 	if !observe.SkipObserve() {
 		// If running in GCP and enabled, export traces, metrics and errors to Stackdriver
 		if observe.IsGCPEnabled() {
+			gcp = true
 			exp, err := observe.NewStackdriverExporter(projectID, onErr)
 			if err != nil {
 				lg.Log("error", err,
@@ -158,31 +211,50 @@ func NewServer(svc Service) *Server {
 		}
 	}
 
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		stdlog.Fatalf("unable to load TLS config: %s", err)
+	}
+
 	s := &Server{
 		cfg:      cfg,
 		mux:      r,
-		exit:     make(chan chan error),
+		exit:     make(chan stopRequest),
+		stopped:  make(chan struct{}),
 		logger:   lg,
+		logLevel: lg,
 		logClose: logClose,
 		ocFlush:  ocFlush,
 		errs:     errs,
+
+		tlsConfig: tlsConfig,
+		accessLog: newAccessLogger(cfg, gcp),
+	}
+	var handler http.Handler = &ochttp.Handler{Handler: s, Propagation: propr}
+	if cfg.SinglePort && tlsConfig == nil {
+		// allow HTTP/2 requests without TLS when sharing a port with gRPC
+		handler = h2c.NewHandler(handler, &http2.Server{})
 	}
 	s.svr = &http.Server{
-		Handler:        &ochttp.Handler{Handler: s, Propagation: propr},
+		Handler:        handler,
 		Addr:           fmt.Sprintf("%s:%d", cfg.HTTPAddr, cfg.HTTPPort),
 		MaxHeaderBytes: cfg.MaxHeaderBytes,
 		ReadTimeout:    cfg.ReadTimeout,
 		WriteTimeout:   cfg.WriteTimeout,
 		IdleTimeout:    cfg.IdleTimeout,
+		TLSConfig:      tlsConfig,
 	}
 	s.register(svc)
+	s.adminSvr = s.newAdminServer()
 	return s
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if x := recover(); x != nil {
-			s.logger.Log("error", x, "message", "the server encountered a panic", "stacktrace", string(debug.Stack()))
+			id := r.Header.Get(observe.RequestIDKey)
+			s.logger.Log("error", x, "message", "the server encountered a panic", "stacktrace", string(debug.Stack()),
+				"request-id", id)
 
 			w.WriteHeader(http.StatusInternalServerError)
 			_, werr := w.Write([]byte(http.StatusText(http.StatusInternalServerError)))
@@ -197,7 +269,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 			var err error
 			if e, ok := x.(error); ok {
-				err = e
+				err = errors.Wrapf(e, "request %s", id)
 			}
 			s.errs.Report(errorreporting.Entry{
 				Req:   r,
@@ -207,39 +279,60 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	// populate context with helpful keys
-	ctx := httptransport.PopulateRequestContext(r.Context(), r)
+	// add the request ID before populating the context with helpful keys
+	ctx := httpRequestID(r.Context(), w, r)
+	ctx = httptransport.PopulateRequestContext(ctx, r)
 
 	// add google trace header to use in tracing and logging
 	ctx = context.WithValue(ctx, ContextKeyCloudTraceContext,
 		r.Header.Get("X-Cloud-Trace-Context"))
 
+	// add the verified client certificate, if any
+	if cert := verifiedCert(r.TLS); cert != nil {
+		ctx = context.WithValue(ctx, ContextKeyPeerCertificate, cert)
+	}
+
 	// add a request scoped logger to the context
 	ctx = SetLogger(ctx, AddLogKeyVals(ctx, s.logger))
 
+	defer s.trackRequest()()
+
+	if s.accessLog != nil {
+		s.accessLog.serveHTTP(w, r.WithContext(ctx), s.handler)
+		return
+	}
 	s.handler.ServeHTTP(w, r.WithContext(ctx))
 }
 
 func (s *Server) register(svc Service) {
 	s.svc = svc
-	s.handler = s.svc.HTTPMiddleware(s.mux)
+	s.handler = compression(s.cfg, s.svc.HTTPMiddleware(s.mux))
 
 	const warmupPath = "/_ah/warmup"
 	var (
 		healthzFound bool
+		readyFound   bool
 		warmupFound  bool
+		routes       = map[string]bool{}
 	)
 
-	opts := svc.HTTPOptions()
+	// default to the kit error encoder, services may override it with their options
+	opts := append([]httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(EncodeErrorResponse),
+	}, svc.HTTPOptions()...)
 
 	// register all endpoints with our wrappers & default decoders/encoders
 	for path, epMethods := range svc.HTTPEndpoints() {
 		for method, ep := range epMethods {
+			routes[method+" "+path] = true
 
 			// check if folks are supplying their own healthcheck
 			if method == http.MethodGet && path == s.cfg.HealthCheckPath {
 				healthzFound = true
 			}
+			if method == http.MethodGet && path == s.cfg.ReadinessPath {
+				readyFound = true
+			}
 
 			// check for a GAE "warm up" request endpoint
 			if method == http.MethodGet && path == warmupPath {
@@ -255,9 +348,9 @@ func (s *Server) register(svc Service) {
 				ep.Encoder = httptransport.EncodeJSONResponse
 			}
 			s.mux.Handle(method, path,
-				ochttp.WithRouteTag(
+				withRoute(
 					httptransport.NewServer(
-						svc.Middleware(ep.Endpoint),
+						svc.Middleware(validateEndpoint(ep.Endpoint)),
 						ep.Decoder,
 						ep.Encoder,
 						append(opts, ep.Options...)...), path))
@@ -267,7 +360,7 @@ func (s *Server) register(svc Service) {
 	// register a simple health check if none provided
 	if !healthzFound {
 		s.mux.Handle(http.MethodGet, s.cfg.HealthCheckPath,
-			ochttp.WithRouteTag(
+			withRoute(
 				httptransport.NewServer(
 					svc.Middleware(okEndpoint),
 					basicDecoder,
@@ -275,10 +368,26 @@ func (s *Server) register(svc Service) {
 					opts...), s.cfg.HealthCheckPath))
 	}
 
+	// register a readiness check running any dependency checks if none provided
+	var checks map[string]HealthCheck
+	if hc, ok := svc.(HealthChecker); ok {
+		checks = hc.HealthChecks()
+	}
+	s.ready = newReadiness(s.cfg, checks)
+	if !readyFound {
+		s.mux.Handle(http.MethodGet, s.cfg.ReadinessPath,
+			withRoute(
+				httptransport.NewServer(
+					svc.Middleware(s.ready.endpoint),
+					basicDecoder,
+					httptransport.EncodeJSONResponse,
+					opts...), s.cfg.ReadinessPath))
+	}
+
 	// register a warmup request for App Engine apps that dont have one already.
 	if !warmupFound {
 		s.mux.Handle(http.MethodGet, warmupPath,
-			ochttp.WithRouteTag(
+			withRoute(
 				httptransport.NewServer(
 					svc.Middleware(okEndpoint),
 					basicDecoder,
@@ -286,31 +395,85 @@ func (s *Server) register(svc Service) {
 					opts...), warmupPath))
 	}
 
-	// add all pprof endpoints by default to HTTP
-	registerPprof(s.cfg, s.mux)
+	// operational endpoints are served by the admin listener, if enabled
+	if s.cfg.AdminPort == 0 {
+		// add all pprof endpoints by default to HTTP
+		registerPprof(s.cfg, s.mux)
+
+		if err := registerMetrics(s.cfg, s.mux, s.logger); err != nil {
+			s.logger.Log("error", err, "message", "unable to register metrics endpoint")
+		}
+	}
 
 	gdesc := svc.RPCServiceDesc()
 	if gdesc == nil {
 		return
 	}
 
+	// serve the gRPC methods over HTTP/JSON if requested
+	if ts, ok := svc.(HTTPTranscodingService); ok {
+		if err := s.registerTranscoding(svc, ts, gdesc, opts, routes); err != nil {
+			s.logger.Log("error", err, "message", "unable to register HTTP transcoding endpoints")
+		}
+	}
+
 	inters := []grpc.UnaryServerInterceptor{
 		grpc.UnaryServerInterceptor(
 			// inject logger into gRPC server and hook in go-kit middleware
 			func(ctx ocontext.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+				defer s.trackRequest()()
+				start := time.Now()
+				ctx = withRPCPeerCertificate(ctx)
+				ctx = setRPCRequestID(ctx)
 				ctx = context.WithValue(ctx, logKey, AddLogKeyVals(ctx, s.logger))
-				return svc.Middleware(func(ctx context.Context, req interface{}) (interface{}, error) {
+				resp, err = svc.Middleware(func(ctx context.Context, req interface{}) (interface{}, error) {
 					return handler(ctx, req)
 				})(ctx, req)
+				err = toRPCError(err)
+				s.accessLog.logRPC(ctx, info.FullMethod, start, err, req, resp)
+				return resp, err
 			},
 		),
 	}
 	if mw := svc.RPCMiddleware(); mw != nil {
 		inters = append(inters, mw)
 	}
+	inters = append(inters, validateInterceptor)
+
+	streamInters := []grpc.StreamServerInterceptor{
+		grpc.StreamServerInterceptor(
+			// inject logger into the stream context and hook in go-kit middleware
+			func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				defer s.trackRequest()()
+				start := time.Now()
+				ws := grpc_middleware.WrapServerStream(ss)
+				ws.WrappedContext = withRPCPeerCertificate(ws.WrappedContext)
+				ws.WrappedContext = setStreamRequestID(ws.WrappedContext, ss)
+				ws.WrappedContext = context.WithValue(ws.WrappedContext, logKey,
+					AddLogKeyVals(ws.WrappedContext, s.logger))
+				_, err := svc.Middleware(func(ctx context.Context, req interface{}) (interface{}, error) {
+					ws.WrappedContext = ctx
+					return nil, handler(srv, ws)
+				})(ws.WrappedContext, ws)
+				err = toRPCError(err)
+				s.accessLog.logRPC(ws.WrappedContext, info.FullMethod, start, err, nil, nil)
+				return err
+			},
+		),
+	}
+	if ss, ok := svc.(RPCStreamService); ok {
+		if mw := ss.RPCStreamMiddleware(); mw != nil {
+			streamInters = append(streamInters, mw)
+		}
+	}
 
-	s.gsvr = grpc.NewServer(append(svc.RPCOptions(),
-		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(inters...)),
+	gopts := svc.RPCOptions()
+	if s.tlsConfig != nil {
+		gopts = append(gopts, grpc.Creds(credentials.NewTLS(s.tlsConfig)))
+	}
+	s.gsvr = grpc.NewServer(append(gopts,
+		grpc.ChainUnaryInterceptor(inters...),
+		grpc.ChainStreamInterceptor(streamInters...),
 		grpc.StatsHandler(&ocgrpc.ServerHandler{}))...)
 
 	s.gsvr.RegisterService(gdesc, svc)
@@ -324,51 +487,37 @@ func basicDecoder(_ context.Context, r *http.Request) (interface{}, error) {
 	return r, nil
 }
 
-func (s *Server) start() error {
-	go func() {
-		err := s.svr.ListenAndServe()
-		if err != nil && err != http.ErrServerClosed {
-			s.logger.Log(
-				"error", err,
-				"message", "HTTP server error - initiating shutting down")
-			s.stop()
-		}
-	}()
-
-	s.logger.Log("message",
-		fmt.Sprintf("listening on HTTP port: %d", s.cfg.HTTPPort))
+// Start will bind the HTTP and gRPC listeners and serve requests in the
+// background until Stop is called. The context is only used while binding the
+// listeners.
+func (s *Server) Start(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&s.started, 0, 1) {
+		return errors.New("server has already been started")
+	}
 
-	if s.gsvr != nil {
-		gaddr := fmt.Sprintf(":%d", s.cfg.RPCPort)
-		lis, err := net.Listen("tcp", gaddr)
-		if err != nil {
-			return errors.Wrap(err, "failed to listen to RPC port")
+	alis, err := s.listenAdmin(ctx)
+	if err == nil {
+		if s.cfg.SinglePort {
+			err = s.startSinglePort(ctx)
+		} else {
+			err = s.startPorts(ctx)
+		}
+		if err != nil && alis != nil {
+			alis.Close()
 		}
-
-		go func() {
-			err := s.gsvr.Serve(lis)
-			// the gRPC server _always_ returns non-nil
-			// this filters out the known err we don't care about logging
-			if (err != nil) && strings.Contains(err.Error(), "use of closed network connection") {
-				err = nil
-			}
-			if err != nil {
-				s.logger.Log(
-					"error", err,
-					"message", "gRPC server error - initiating shutting down")
-				s.stop()
-			}
-		}()
-		s.logger.Log("message",
-			fmt.Sprintf("listening on RPC port: %d", s.cfg.RPCPort))
+	}
+	if err != nil {
+		// there is nothing to shut down
+		close(s.stopped)
+		return err
+	}
+	if alis != nil {
+		s.serveAdmin(alis)
 	}
 
 	go func() {
-		exit := <-s.exit
+		req := <-s.exit
 
-		// stop the listener with timeout
-		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
-		defer cancel()
 		defer func() {
 			// flush the logger after server shuts down
 			if s.logClose != nil {
@@ -385,22 +534,180 @@ func (s *Server) start() error {
 			}
 		}()
 
-		if shutdown, ok := s.svc.(Shutdowner); ok {
-			shutdown.Shutdown()
+		s.stopErr = s.shutdown(req.ctx)
+		close(s.stopped)
+		req.err <- s.stopErr
+	}()
+
+	return nil
+}
+
+// Stop will gracefully shut down a started server. The server will stop once all
+// in-flight requests complete, the ShutdownTimeout is reached or the context is
+// done, whichever is first. Calling Stop again will return the same result.
+func (s *Server) Stop(ctx context.Context) error {
+	if atomic.LoadInt32(&s.started) == 0 {
+		return errors.New("server has not been started")
+	}
+	req := stopRequest{ctx: ctx, err: make(chan error, 1)}
+	select {
+	case s.exit <- req:
+		return <-req.err
+	case <-s.stopped:
+		return s.stopErr
+	}
+}
+
+// stopRequest asks the server to shut down and waits for the result.
+type stopRequest struct {
+	ctx context.Context
+	err chan error
+}
+
+// Addr returns the address the HTTP server is listening on. It is empty until
+// the server is started.
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// RPCAddr returns the address the gRPC server is listening on. It is the same as
+// Addr when serving on a single port and empty until the server is started or if
+// the Service has no gRPC service.
+func (s *Server) RPCAddr() string {
+	return s.rpcAddr
+}
+
+// startPorts will serve HTTP and gRPC on their own ports.
+func (s *Server) startPorts(ctx context.Context) error {
+	var lc net.ListenConfig
+	hlis, err := lc.Listen(ctx, "tcp", s.svr.Addr)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen to HTTP port")
+	}
+	var glis net.Listener
+	if s.gsvr != nil && !s.rpcOverHTTP {
+		glis, err = lc.Listen(ctx, "tcp", fmt.Sprintf(":%d", s.cfg.RPCPort))
+		if err != nil {
+			hlis.Close()
+			return errors.Wrap(err, "failed to listen to RPC port")
+		}
+	}
+
+	s.addr = hlis.Addr().String()
+	go func() {
+		var err error
+		if s.tlsConfig != nil {
+			// the certificate is provided by the TLS config
+			err = s.svr.ServeTLS(hlis, "", "")
+		} else {
+			err = s.svr.Serve(hlis)
 		}
+		if err != nil && err != http.ErrServerClosed {
+			s.logger.Log(
+				"error", err,
+				"message", "HTTP server error - initiating shutting down")
+			s.Stop(context.Background())
+		}
+	}()
+
+	s.logger.Log("message",
+		fmt.Sprintf("listening on HTTP port: %d", listenerPort(hlis)))
+
+	if s.rpcOverHTTP {
+		s.rpcAddr = s.addr
+	}
+	if glis != nil {
+		s.rpcAddr = glis.Addr().String()
+		go s.serveRPC(glis)
+		s.logger.Log("message",
+			fmt.Sprintf("listening on RPC port: %d", listenerPort(glis)))
+	}
+	return nil
+}
+
+// startSinglePort will serve HTTP and gRPC on the HTTP port, sending any HTTP/2
+// requests with a gRPC content-type to the gRPC server.
+func (s *Server) startSinglePort(ctx context.Context) error {
+	if s.tlsConfig != nil {
+		// connections can't be matched by content-type before the TLS handshake
+		// so gRPC requests are dispatched by the HTTP server instead.
 		if s.gsvr != nil {
-			s.gsvr.GracefulStop()
+			s.rpcOverHTTP = true
+			s.svr.Handler = grpcHandler(s.gsvr, s.svr.Handler)
+		}
+		return s.startPorts(ctx)
+	}
+
+	var lc net.ListenConfig
+	lis, err := lc.Listen(ctx, "tcp", s.svr.Addr)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen to HTTP port")
+	}
+
+	s.addr = lis.Addr().String()
+	m := cmux.New(lis)
+	if s.gsvr != nil {
+		s.rpcAddr = s.addr
+		go s.serveRPC(m.MatchWithWriters(
+			cmux.HTTP2MatchHeaderFieldPrefixSendSettings("content-type", "application/grpc")))
+	}
+	httpLis := m.Match(cmux.Any())
+
+	go func() {
+		err := s.svr.Serve(httpLis)
+		if !isClosedErr(err) {
+			s.logger.Log(
+				"error", err,
+				"message", "HTTP server error - initiating shutting down")
+			s.Stop(context.Background())
+		}
+	}()
+	go func() {
+		// the mux stops serving once the gRPC server or the HTTP server
+		// closes the shared listener during shutdown
+		err := m.Serve()
+		if !isClosedErr(err) {
+			s.logger.Log(
+				"error", err,
+				"message", "single port listener error - initiating shutting down")
+			s.Stop(context.Background())
 		}
-		exit <- s.svr.Shutdown(ctx)
 	}()
 
+	s.logger.Log("message",
+		fmt.Sprintf("listening on HTTP and RPC port: %d", listenerPort(lis)))
 	return nil
 }
 
-func (s *Server) stop() error {
-	ch := make(chan error)
-	s.exit <- ch
-	return <-ch
+// listenerPort returns the port a TCP listener is bound to.
+func listenerPort(lis net.Listener) int {
+	if addr, ok := lis.Addr().(*net.TCPAddr); ok {
+		return addr.Port
+	}
+	return 0
+}
+
+func (s *Server) serveRPC(lis net.Listener) {
+	err := s.gsvr.Serve(lis)
+	// the gRPC server _always_ returns non-nil
+	// this filters out the known err we don't care about logging
+	if !isClosedErr(err) {
+		s.logger.Log(
+			"error", err,
+			"message", "gRPC server error - initiating shutting down")
+		s.Stop(context.Background())
+	}
+}
+
+// isClosedErr returns true if the error is nil or is expected from a server
+// or listener that was closed during shutdown.
+func isClosedErr(err error) bool {
+	return err == nil ||
+		err == http.ErrServerClosed ||
+		err == grpc.ErrServerStopped ||
+		err == cmux.ErrListenerClosed ||
+		err == cmux.ErrServerClosed ||
+		strings.Contains(err.Error(), "use of closed network connection")
 }
 
 func registerPprof(cfg Config, mx Router) {
@@ -417,4 +724,6 @@ func registerPprof(cfg Config, mx Router) {
 	mx.Handle(http.MethodGet, "/debug/pprof/heap", pprof.Handler("heap"))
 	mx.Handle(http.MethodGet, "/debug/pprof/threadcreate", pprof.Handler("threadcreate"))
 	mx.Handle(http.MethodGet, "/debug/pprof/block", pprof.Handler("block"))
+	mx.Handle(http.MethodGet, "/debug/pprof/allocs", pprof.Handler("allocs"))
+	mx.Handle(http.MethodGet, "/debug/pprof/mutex", pprof.Handler("mutex"))
 }
@@ -0,0 +1,90 @@
+package kit
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+)
+
+func TestKitServerSinglePort(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := lis.Addr().(*net.TCPAddr).Port
+	lis.Close()
+
+	os.Setenv("GIZMO_SINGLE_PORT", "true")
+	os.Setenv("HTTP_ADDR", "127.0.0.1")
+	os.Setenv("HTTP_PORT", strconv.Itoa(port))
+	defer os.Unsetenv("GIZMO_SINGLE_PORT")
+	defer os.Unsetenv("HTTP_ADDR")
+	defer os.Unsetenv("HTTP_PORT")
+
+	svr := NewServer(&streamService{})
+	if err := svr.Start(context.Background()); err != nil {
+		t.Fatalf("unable to start server: %s", err)
+	}
+	addr := "127.0.0.1:" + strconv.Itoa(port)
+
+	// HTTP/1.1
+	resp, err := http.Get("http://" + addr + "/healthz")
+	if err != nil {
+		t.Fatalf("unable to make HTTP/1.1 request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 1 {
+		t.Errorf("expected 200 over HTTP/1, got %d over %s", resp.StatusCode, resp.Proto)
+	}
+
+	// HTTP/2 cleartext
+	h2c := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	resp, err = h2c.Get("http://" + addr + "/healthz")
+	if err != nil {
+		t.Fatalf("unable to make h2c request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+		t.Errorf("expected 200 over HTTP/2, got %d over %s", resp.StatusCode, resp.Proto)
+	}
+
+	// gRPC
+	cc, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("unable to init gRPC connection: %s", err)
+	}
+	stream, err := cc.NewStream(context.Background(), &streamServiceDesc.Streams[0],
+		"/kit_test.StreamService/Echo")
+	if err != nil {
+		t.Fatalf("unable to open stream: %s", err)
+	}
+	if err = stream.SendMsg(&wrappers.StringValue{Value: "ziggy"}); err != nil {
+		t.Fatalf("unable to send message: %s", err)
+	}
+	stream.CloseSend()
+	var got wrappers.StringValue
+	if err = stream.RecvMsg(&got); err != nil {
+		t.Fatalf("unable to receive message: %s", err)
+	}
+	if got.Value != "ziggy" {
+		t.Errorf("expected echoed value %q, got %q", "ziggy", got.Value)
+	}
+	cc.Close()
+
+	if err := svr.Stop(context.Background()); err != nil {
+		t.Errorf("unexpected error on shutdown: %s", err)
+	}
+}
@@ -0,0 +1,182 @@
+package kit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+)
+
+type streamCtxKey struct{}
+
+func TestKitServerRPCStream(t *testing.T) {
+	svc := &streamService{}
+	svr := NewServer(svc)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go svr.gsvr.Serve(lis)
+	defer svr.gsvr.Stop()
+
+	cc, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("unable to init gRPC connection: %s", err)
+	}
+	defer cc.Close()
+
+	stream, err := cc.NewStream(context.Background(), &streamServiceDesc.Streams[0],
+		"/kit_test.StreamService/Echo")
+	if err != nil {
+		t.Fatalf("unable to open stream: %s", err)
+	}
+	if err = stream.SendMsg(&wrappers.StringValue{Value: "ziggy"}); err != nil {
+		t.Fatalf("unable to send message: %s", err)
+	}
+	if err = stream.CloseSend(); err != nil {
+		t.Fatalf("unable to close send: %s", err)
+	}
+	var got wrappers.StringValue
+	if err = stream.RecvMsg(&got); err != nil {
+		t.Fatalf("unable to receive message: %s", err)
+	}
+
+	if got.Value != "ziggy" {
+		t.Errorf("expected echoed value %q, got %q", "ziggy", got.Value)
+	}
+	if !svc.middleware {
+		t.Error("expected service Middleware to be called for the stream")
+	}
+	if !svc.streamMiddleware {
+		t.Error("expected RPCStreamMiddleware to be called for the stream")
+	}
+	if !svc.ctxValue {
+		t.Error("expected stream context to carry values set by Middleware")
+	}
+}
+
+func TestKitServerRPCOptionsInterceptors(t *testing.T) {
+	svc := &interceptorService{streamService: &streamService{}}
+	// services could always pass their own interceptors
+	svr := NewServer(svc)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go svr.gsvr.Serve(lis)
+	defer svr.gsvr.Stop()
+
+	cc, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("unable to init gRPC connection: %s", err)
+	}
+	defer cc.Close()
+
+	stream, err := cc.NewStream(context.Background(), &streamServiceDesc.Streams[0],
+		"/kit_test.StreamService/Echo")
+	if err != nil {
+		t.Fatalf("unable to open stream: %s", err)
+	}
+	stream.SendMsg(&wrappers.StringValue{Value: "ziggy"})
+	stream.CloseSend()
+	if err = stream.RecvMsg(&wrappers.StringValue{}); err != nil {
+		t.Fatalf("unable to receive message: %s", err)
+	}
+
+	if !svc.streamIntercepted {
+		t.Error("expected the stream interceptor from RPCOptions to be called")
+	}
+	if !svc.streamMiddleware {
+		t.Error("expected RPCStreamMiddleware to be called for the stream")
+	}
+}
+
+type interceptorService struct {
+	*streamService
+	streamIntercepted bool
+}
+
+func (s *interceptorService) RPCOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, h grpc.UnaryHandler) (interface{}, error) {
+			return h(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+			s.streamIntercepted = true
+			return h(srv, ss)
+		}),
+	}
+}
+
+type streamService struct {
+	middleware       bool
+	streamMiddleware bool
+	ctxValue         bool
+	peerName         string
+}
+
+func (s *streamService) Middleware(e endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, r interface{}) (interface{}, error) {
+		if _, ok := r.(grpc.ServerStream); ok {
+			s.middleware = true
+		}
+		return e(context.WithValue(ctx, streamCtxKey{}, true), r)
+	}
+}
+
+func (s *streamService) HTTPMiddleware(h http.Handler) http.Handler        { return h }
+func (s *streamService) HTTPOptions() []httptransport.ServerOption         { return nil }
+func (s *streamService) HTTPRouterOptions() []RouterOption                 { return nil }
+func (s *streamService) HTTPEndpoints() map[string]map[string]HTTPEndpoint { return nil }
+func (s *streamService) RPCMiddleware() grpc.UnaryServerInterceptor        { return nil }
+func (s *streamService) RPCOptions() []grpc.ServerOption                   { return nil }
+func (s *streamService) RPCServiceDesc() *grpc.ServiceDesc                 { return &streamServiceDesc }
+
+func (s *streamService) RPCStreamMiddleware() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		s.streamMiddleware = true
+		LogMsg(ss.Context(), "rpc stream middleware!")
+		return handler(srv, ss)
+	}
+}
+
+func (s *streamService) echo(stream grpc.ServerStream) error {
+	s.ctxValue, _ = stream.Context().Value(streamCtxKey{}).(bool)
+	if cert := PeerCertificate(stream.Context()); cert != nil {
+		s.peerName = cert.Subject.CommonName
+	}
+	LogMsg(stream.Context(), "echoing")
+
+	var in wrappers.StringValue
+	if err := stream.RecvMsg(&in); err != nil {
+		return err
+	}
+	return stream.SendMsg(&in)
+}
+
+type streamServer interface {
+	echo(grpc.ServerStream) error
+}
+
+var streamServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kit_test.StreamService",
+	HandlerType: (*streamServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Echo",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(streamServer).echo(stream)
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+}
@@ -0,0 +1,96 @@
+// Package kittest provides utilities for end-to-end testing of gizmo kit
+// services over real HTTP and gRPC connections.
+//
+// A Server is started on ephemeral local ports so tests can run in parallel:
+//
+//	svr := kittest.NewServer(&service{})
+//	defer svr.Close()
+//
+//	resp, err := svr.Client.Get(svr.URL + "/svc/cats")
+//	...
+//	client := pb.NewCatServiceClient(svr.Conn)
+//	...
+package kittest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"google.golang.org/grpc"
+
+	"github.com/NYTimes/gizmo/server/kit"
+)
+
+// Server is a started kit.Server along with clients for it.
+type Server struct {
+	*kit.Server
+
+	// URL is the base URL of the HTTP server, like http://127.0.0.1:50000.
+	URL string
+	// Client is an HTTP client for the server.
+	Client *http.Client
+	// Conn is a gRPC client connection to the server. It is nil if the Service
+	// does not provide an RPCServiceDesc.
+	Conn *grpc.ClientConn
+}
+
+// NewServer will start a kit server for the given Service on ephemeral ports of
+// the loopback interface. The rest of the Config is loaded from the environment,
+// except for TLS, which is disabled. It will panic if the server can't be started.
+// Callers should call Close when finished to shut it down.
+func NewServer(svc kit.Service) *Server {
+	cfg := kit.LoadConfig()
+	cfg.HTTPAddr = "127.0.0.1"
+	cfg.HTTPPort, cfg.RPCPort = 0, 0
+	cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile = "", "", ""
+	return NewServerWithConfig(svc, cfg)
+}
+
+// NewServerWithConfig will start a kit server for the given Service using the
+// given Config as is. The clients do not use TLS. It will panic if the server
+// can't be started.
+func NewServerWithConfig(svc kit.Service, cfg kit.Config) *Server {
+	s := &Server{
+		Server: kit.NewServerWithConfig(svc, cfg),
+		Client: &http.Client{Transport: &http.Transport{}},
+	}
+	if err := s.Start(context.Background()); err != nil {
+		panic(fmt.Sprintf("kittest: failed to start server: %s", err))
+	}
+	s.URL = "http://" + dialAddr(s.Addr())
+
+	if addr := s.RPCAddr(); addr != "" {
+		conn, err := grpc.Dial(dialAddr(addr), grpc.WithInsecure())
+		if err != nil {
+			s.Stop(context.Background())
+			panic(fmt.Sprintf("kittest: failed to dial gRPC server: %s", err))
+		}
+		s.Conn = conn
+	}
+	return s
+}
+
+// Close will close the clients and gracefully shut down the server, returning
+// any error from the shutdown.
+func (s *Server) Close() error {
+	if s.Conn != nil {
+		s.Conn.Close()
+	}
+	s.Client.CloseIdleConnections()
+	return s.Stop(context.Background())
+}
+
+// dialAddr will replace an unspecified host, like the one of a server bound to
+// all interfaces, with the loopback address.
+func dialAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,124 @@
+package kittest_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+
+	"github.com/NYTimes/gizmo/server/kit"
+	"github.com/NYTimes/gizmo/server/kit/kittest"
+)
+
+func TestServer(t *testing.T) {
+	svr := kittest.NewServer(&service{})
+	if svr.Addr() == svr.RPCAddr() {
+		t.Errorf("expected HTTP and gRPC on separate ports, got %s", svr.Addr())
+	}
+
+	resp, err := svr.Client.Get(svr.URL + "/hello")
+	if err != nil {
+		t.Fatalf("unable to make HTTP request: %s", err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(b) != "\"hello\"\n" {
+		t.Errorf("expected 200 and \"hello\", got %d and %q", resp.StatusCode, b)
+	}
+
+	var out wrappers.StringValue
+	err = svr.Conn.Invoke(context.Background(), "/kittest.Service/Echo",
+		&wrappers.StringValue{Value: "ziggy"}, &out)
+	if err != nil {
+		t.Fatalf("unable to make gRPC request: %s", err)
+	}
+	if out.Value != "ziggy" {
+		t.Errorf("expected echo of \"ziggy\", got %q", out.Value)
+	}
+
+	if err := svr.Close(); err != nil {
+		t.Errorf("unexpected error on close: %s", err)
+	}
+	// stopping again returns the same result
+	if err := svr.Stop(context.Background()); err != nil {
+		t.Errorf("unexpected error on second stop: %s", err)
+	}
+	if _, err := http.Get(svr.URL + "/hello"); err == nil {
+		t.Error("expected server to be closed")
+	}
+}
+
+func TestServerSinglePort(t *testing.T) {
+	os.Setenv("GIZMO_SINGLE_PORT", "true")
+	defer os.Unsetenv("GIZMO_SINGLE_PORT")
+
+	svr := kittest.NewServer(&service{})
+	defer svr.Close()
+	if svr.Addr() != svr.RPCAddr() {
+		t.Errorf("expected HTTP and gRPC on the same port, got %s and %s",
+			svr.Addr(), svr.RPCAddr())
+	}
+
+	var out wrappers.StringValue
+	err := svr.Conn.Invoke(context.Background(), "/kittest.Service/Echo",
+		&wrappers.StringValue{Value: "ziggy"}, &out)
+	if err != nil {
+		t.Fatalf("unable to make gRPC request: %s", err)
+	}
+}
+
+type service struct{}
+
+func (s *service) Middleware(e endpoint.Endpoint) endpoint.Endpoint { return e }
+
+func (s *service) HTTPMiddleware(h http.Handler) http.Handler { return h }
+func (s *service) HTTPOptions() []httptransport.ServerOption  { return nil }
+func (s *service) HTTPRouterOptions() []kit.RouterOption      { return nil }
+func (s *service) RPCMiddleware() grpc.UnaryServerInterceptor { return nil }
+func (s *service) RPCOptions() []grpc.ServerOption            { return nil }
+func (s *service) RPCServiceDesc() *grpc.ServiceDesc          { return &serviceDesc }
+
+func (s *service) HTTPEndpoints() map[string]map[string]kit.HTTPEndpoint {
+	return map[string]map[string]kit.HTTPEndpoint{
+		"/hello": {"GET": {
+			Endpoint: func(context.Context, interface{}) (interface{}, error) {
+				return "hello", nil
+			},
+		}},
+	}
+}
+
+func (s *service) echo(ctx context.Context, in *wrappers.StringValue) (*wrappers.StringValue, error) {
+	return in, nil
+}
+
+type echoServer interface {
+	echo(context.Context, *wrappers.StringValue) (*wrappers.StringValue, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "kittest.Service",
+	HandlerType: (*echoServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Echo",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(wrappers.StringValue)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/kittest.Service/Echo"}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(echoServer).echo(ctx, req.(*wrappers.StringValue))
+				}
+				return interceptor(ctx, in, info, handler)
+			},
+		},
+	},
+}
@@ -0,0 +1,243 @@
+// Package limiter provides an adaptive concurrency limiter to shed load from
+// gizmo kit servers when they or their dependencies slow down.
+//
+// A Limiter allows a limited number of requests to be in flight at once and
+// rejects any excess requests early with a 503 Service Unavailable response over
+// HTTP and an Unavailable status over gRPC. The limit is adjusted with an
+// additive-increase/multiplicative-decrease (AIMD) algorithm: it grows while
+// requests complete within the LatencyThreshold and shrinks, at most once per
+// LatencyThreshold, when requests are slower or fail with a timeout or overload
+// error.
+//
+// A Limiter can be applied to a whole Service in its Middleware method or to
+// individual routes by wrapping the Endpoint of an HTTPEndpoint:
+//
+//	"/cats": {
+//	    "GET": {
+//	        Endpoint: s.catsLimiter.Middleware(s.getCats),
+//	    },
+//	},
+//
+// The current limit of each Limiter is recorded to OpenCensus. Register
+// DefaultViews to export it.
+package limiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/NYTimes/gizmo/server/kit"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrLimitExceeded is returned by the Limiter middleware when a request is
+// rejected because the concurrency limit has been reached.
+var ErrLimitExceeded = kit.NewError(codes.Unavailable, "concurrency limit exceeded")
+
+// Config holds the settings of a Limiter. Zero values will use the defaults.
+type Config struct {
+	// Name identifies the Limiter in metrics. It defaults to "default".
+	Name string
+	// InitialLimit is the concurrency limit to start with. It defaults to 20.
+	InitialLimit int
+	// MinLimit is the lowest the concurrency limit can drop to. It defaults to 1.
+	MinLimit int
+	// MaxLimit is the highest the concurrency limit can grow to. It defaults to 1000.
+	MaxLimit int
+	// LatencyThreshold is the latency above which a request is considered a
+	// sign of overload. It is also the window the limit is decreased at most
+	// once in. It defaults to 1s.
+	LatencyThreshold time.Duration
+	// BackoffRatio is the factor the limit is multiplied by on overload. It must
+	// be between 0 and 1 and defaults to 0.9.
+	BackoffRatio float64
+}
+
+// The defaults used for zero Config values.
+const (
+	DefaultInitialLimit     = 20
+	DefaultMinLimit         = 1
+	DefaultMaxLimit         = 1000
+	DefaultLatencyThreshold = time.Second
+	DefaultBackoffRatio     = 0.9
+)
+
+func (c Config) withDefaults() Config {
+	if c.Name == "" {
+		c.Name = "default"
+	}
+	if c.MinLimit <= 0 {
+		c.MinLimit = DefaultMinLimit
+	}
+	if c.MaxLimit <= 0 {
+		c.MaxLimit = DefaultMaxLimit
+	}
+	if c.MaxLimit < c.MinLimit {
+		c.MaxLimit = c.MinLimit
+	}
+	if c.InitialLimit <= 0 {
+		c.InitialLimit = DefaultInitialLimit
+	}
+	if c.InitialLimit < c.MinLimit {
+		c.InitialLimit = c.MinLimit
+	}
+	if c.InitialLimit > c.MaxLimit {
+		c.InitialLimit = c.MaxLimit
+	}
+	if c.LatencyThreshold <= 0 {
+		c.LatencyThreshold = DefaultLatencyThreshold
+	}
+	if c.BackoffRatio <= 0 || c.BackoffRatio >= 1 {
+		c.BackoffRatio = DefaultBackoffRatio
+	}
+	return c
+}
+
+// Limiter is an adaptive concurrency limiter.
+type Limiter struct {
+	cfg Config
+	ctx context.Context
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	// lastBackoff is when the limit was last decreased.
+	lastBackoff time.Time
+
+	now func() time.Time
+}
+
+// New will return a Limiter with the given Config.
+func New(cfg Config) *Limiter {
+	cfg = cfg.withDefaults()
+	ctx, _ := tag.New(context.Background(), tag.Upsert(KeyName, cfg.Name))
+	l := &Limiter{
+		cfg:   cfg,
+		ctx:   ctx,
+		limit: float64(cfg.InitialLimit),
+		now:   time.Now,
+	}
+	stats.Record(ctx, MeasureLimit.M(int64(cfg.InitialLimit)))
+	return l
+}
+
+// Limit returns the current concurrency limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// InFlight returns the number of requests currently in flight.
+func (l *Limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// Middleware will reject requests with ErrLimitExceeded when the limit has
+// been reached and adjust the limit based on the outcome of the rest.
+func (l *Limiter) Middleware(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, req interface{}) (res interface{}, err error) {
+		if !l.acquire() {
+			stats.Record(l.ctx, MeasureRejected.M(1))
+			return nil, ErrLimitExceeded
+		}
+		start := l.now()
+		// release even if the endpoint panics
+		defer func() { l.release(start, err) }()
+		return next(ctx, req)
+	}
+}
+
+func (l *Limiter) acquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight >= int(l.limit) {
+		return false
+	}
+	l.inFlight++
+	return true
+}
+
+func (l *Limiter) release(start time.Time, err error) {
+	now := l.now()
+	l.mu.Lock()
+	inFlight := l.inFlight
+	l.inFlight--
+
+	prev := int(l.limit)
+	if now.Sub(start) > l.cfg.LatencyThreshold || isOverload(err) {
+		// a single stall slows every request in flight, so only back off once
+		// per window
+		if l.lastBackoff.IsZero() || now.Sub(l.lastBackoff) >= l.cfg.LatencyThreshold {
+			l.limit = math.Max(float64(l.cfg.MinLimit), l.limit*l.cfg.BackoffRatio)
+			l.lastBackoff = now
+		}
+	} else if inFlight*2 >= int(l.limit) {
+		// only grow the limit while it is being used
+		l.limit = math.Min(float64(l.cfg.MaxLimit), l.limit+1/l.limit)
+	}
+	limit := int(l.limit)
+	l.mu.Unlock()
+
+	if limit != prev {
+		stats.Record(l.ctx, MeasureLimit.M(int64(limit)))
+	}
+}
+
+// isOverload returns true if the error signals a timeout or an overloaded
+// dependency. ResourceExhausted is not included as it is usually a per client
+// rate limit rather than a sign of saturation.
+func isOverload(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Cause(err) == context.DeadlineExceeded {
+		return true
+	}
+	switch kit.FromRPCError(err).Code {
+	case codes.DeadlineExceeded, codes.Unavailable:
+		return true
+	}
+	return false
+}
+
+var (
+	// KeyName is the tag key of the Limiter's name.
+	KeyName, _ = tag.NewKey("limiter")
+
+	// MeasureLimit is the current concurrency limit of a Limiter.
+	MeasureLimit = stats.Int64("gizmo.io/kit/limiter/limit",
+		"The current concurrency limit", stats.UnitDimensionless)
+	// MeasureRejected is the number of requests rejected by a Limiter.
+	MeasureRejected = stats.Int64("gizmo.io/kit/limiter/rejected",
+		"The number of requests rejected by the concurrency limiter", stats.UnitDimensionless)
+
+	// LimitView reports the last concurrency limit of each Limiter.
+	LimitView = &view.View{
+		Name:        "gizmo.io/kit/limiter/limit",
+		Description: "The current concurrency limit",
+		Measure:     MeasureLimit,
+		TagKeys:     []tag.Key{KeyName},
+		Aggregation: view.LastValue(),
+	}
+	// RejectedView counts the requests rejected by each Limiter.
+	RejectedView = &view.View{
+		Name:        "gizmo.io/kit/limiter/rejected",
+		Description: "The number of requests rejected by the concurrency limiter",
+		Measure:     MeasureRejected,
+		TagKeys:     []tag.Key{KeyName},
+		Aggregation: view.Count(),
+	}
+
+	// DefaultViews are the views of all Limiter metrics.
+	DefaultViews = []*view.View{LimitView, RejectedView}
+)
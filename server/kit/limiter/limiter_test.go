@@ -0,0 +1,183 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLimiterRejects(t *testing.T) {
+	l := New(Config{Name: "rejects", InitialLimit: 2})
+
+	block := make(chan struct{})
+	started := make(chan struct{})
+	ep := l.Middleware(func(context.Context, interface{}) (interface{}, error) {
+		started <- struct{}{}
+		<-block
+		return "OK", nil
+	})
+
+	done := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := ep(context.Background(), nil)
+			done <- err
+		}()
+		<-started
+	}
+
+	_, err := ep(context.Background(), nil)
+	if err != ErrLimitExceeded {
+		t.Fatalf("expected ErrLimitExceeded, got %v", err)
+	}
+	if got := ErrLimitExceeded.StatusCode(); got != http.StatusServiceUnavailable {
+		t.Errorf("expected status code of 503, got %d", got)
+	}
+	if got := status.Code(ErrLimitExceeded); got != codes.Unavailable {
+		t.Errorf("expected Unavailable status, got %s", got)
+	}
+
+	close(block)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+	if got := l.InFlight(); got != 0 {
+		t.Errorf("expected no requests in flight, got %d", got)
+	}
+}
+
+func TestLimiterBacksOffOncePerWindow(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := New(Config{Name: "window", InitialLimit: 20, LatencyThreshold: time.Second})
+	l.now = func() time.Time { return now }
+	ep := l.Middleware(func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.Unavailable, "stalled")
+	})
+
+	// a burst of failures from a single stall
+	for i := 0; i < 50; i++ {
+		ep(context.Background(), nil)
+	}
+	if got := l.Limit(); got != 18 {
+		t.Errorf("expected a single backoff to 18, got %d", got)
+	}
+
+	now = now.Add(time.Second)
+	ep(context.Background(), nil)
+	if got := l.Limit(); got != 16 {
+		t.Errorf("expected another backoff in the next window, got %d", got)
+	}
+}
+
+func TestLimiterPanic(t *testing.T) {
+	l := New(Config{Name: "panic", InitialLimit: 1})
+	ep := l.Middleware(func(context.Context, interface{}) (interface{}, error) {
+		panic("boom")
+	})
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the panic to be propagated")
+			}
+		}()
+		ep(context.Background(), nil)
+	}()
+	if got := l.InFlight(); got != 0 {
+		t.Errorf("expected the slot to be released after a panic, got %d in flight", got)
+	}
+}
+
+func TestLimiterAdapts(t *testing.T) {
+	if err := view.Register(LimitView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(LimitView)
+
+	now := time.Unix(0, 0)
+	l := New(Config{
+		Name:             "adapts",
+		InitialLimit:     10,
+		MinLimit:         5,
+		LatencyThreshold: time.Second,
+		BackoffRatio:     0.5,
+	})
+	l.now = func() time.Time { return now }
+
+	var (
+		latency time.Duration
+		err     error
+	)
+	ep := l.Middleware(func(context.Context, interface{}) (interface{}, error) {
+		now = now.Add(latency)
+		return nil, err
+	})
+
+	// slow requests shrink the limit down to the minimum
+	latency = 2 * time.Second
+	ep(context.Background(), nil)
+	if got := l.Limit(); got != 5 {
+		t.Errorf("expected limit of 5 after a slow request, got %d", got)
+	}
+	ep(context.Background(), nil)
+	if got := l.Limit(); got != 5 {
+		t.Errorf("expected limit to stay at the minimum of 5, got %d", got)
+	}
+
+	rows, rerr := view.RetrieveData(LimitView.Name)
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	var found bool
+	for _, row := range rows {
+		if len(row.Tags) == 1 && row.Tags[0].Value == "adapts" {
+			found = true
+			if v := row.Data.(*view.LastValueData).Value; v != 5 {
+				t.Errorf("expected recorded limit of 5, got %v", v)
+			}
+		}
+	}
+	if !found {
+		t.Error("expected the limit to be recorded")
+	}
+
+	// overload errors shrink the limit once the window has passed
+	l.limit = 10
+	now = now.Add(time.Second)
+	latency, err = 0, status.Error(codes.DeadlineExceeded, "slow dependency")
+	ep(context.Background(), nil)
+	if got := l.Limit(); got != 5 {
+		t.Errorf("expected limit of 5 after an overload error, got %d", got)
+	}
+
+	// other errors, rate limits and idle capacity leave the limit alone
+	l.limit = 10
+	for _, err = range []error{errors.New("not found"), status.Error(codes.ResourceExhausted, "quota")} {
+		now = now.Add(time.Second)
+		ep(context.Background(), nil)
+		if got := l.Limit(); got != 10 {
+			t.Errorf("expected limit to stay at 10 after %v, got %d", err, got)
+		}
+	}
+
+	// fast requests grow a limit that is in use
+	err = nil
+	l.limit = 2
+	ep(context.Background(), nil)
+	if got := l.Limit(); got != 2 {
+		t.Errorf("expected limit to grow gradually, got %d", got)
+	}
+	ep(context.Background(), nil)
+	ep(context.Background(), nil)
+	if got := l.Limit(); got != 3 {
+		t.Errorf("expected limit of 3 after fast requests, got %d", got)
+	}
+}
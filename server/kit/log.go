@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
+	"sync/atomic"
 
 	"github.com/NYTimes/gizmo/observe"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/go-kit/kit/transport/http"
+	"github.com/kelseyhightower/envconfig"
+	"github.com/pkg/errors"
 	"google.golang.org/grpc/metadata"
 )
 
@@ -28,18 +32,78 @@ import (
 // To speed up start up time in non-GCP enabled environments, this function also checks
 // the observe.SkipObserve() function and will use a basic JSON logger writing to
 // stdout if set.
+// The GIZMO_LOG_LEVEL, GIZMO_LOG_FORMAT and GIZMO_LOG_SAMPLE_* environment
+// variables are applied to the returned logger. See Config for details.
 func NewLogger(ctx context.Context, logID string) (log.Logger, func() error, error) {
+	var lcfg logConfig
+	if err := envconfig.Process("", &lcfg); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid log config")
+	}
+	lg, cl, err := newLogger(ctx, logID, Config{
+		LogLevel:            lcfg.LogLevel,
+		LogFormat:           lcfg.LogFormat,
+		LogSampleInitial:    lcfg.LogSampleInitial,
+		LogSampleThereafter: lcfg.LogSampleThereafter,
+	}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return lg, cl, nil
+}
+
+// logConfig holds the Config fields used by NewLogger so they can be read
+// without the rest of the server's environment.
+type logConfig struct {
+	LogLevel            string `envconfig:"GIZMO_LOG_LEVEL"`
+	LogFormat           string `envconfig:"GIZMO_LOG_FORMAT"`
+	LogSampleInitial    int    `envconfig:"GIZMO_LOG_SAMPLE_INITIAL"`
+	LogSampleThereafter int    `envconfig:"GIZMO_LOG_SAMPLE_THEREAFTER"`
+}
+
+// newLogger will wrap the backend, or the environment's default logger if nil,
+// with the level filter and sampling from the given Config.
+func newLogger(ctx context.Context, logID string, cfg Config, backend log.Logger) (*levelFilter, func() error, error) {
+	min, err := parseLogLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		lg = backend
+		cl = func() error { return nil }
+	)
+	if lg == nil {
+		lg, cl, err = newBaseLogger(ctx, logID, cfg.LogFormat)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if cfg.LogSampleInitial > 0 {
+		lg = newSampler(lg, cfg.LogSampleInitial, cfg.LogSampleThereafter)
+	}
+	return &levelFilter{next: lg, min: min}, cl, nil
+}
+
+func newBaseLogger(ctx context.Context, logID, format string) (log.Logger, func() error, error) {
+	var stdout log.Logger
+	switch format {
+	case "", "json":
+		stdout = newJSONLogger()
+	case "logfmt":
+		stdout = log.NewLogfmtLogger(log.NewSyncWriter(os.Stdout))
+	default:
+		return nil, nil, errors.Errorf("invalid log format %q", format)
+	}
+
 	if observe.SkipObserve() {
-		return newJSONLogger(), func() error { return nil }, nil
+		return stdout, func() error { return nil }, nil
 	}
 	projectID, serviceID, svcVersion := observe.GetServiceInfo()
 
 	lg, cl, err := newStackdriverLogger(ctx, logID, projectID, serviceID, svcVersion)
 	if err != nil {
-		lg := newJSONLogger()
-		lg.Log("error", err,
-			"message", "unable to initialize Stackdriver logger. falling back to stdout JSON logging.")
-		return lg, func() error { return nil }, nil
+		stdout.Log("error", err,
+			"message", "unable to initialize Stackdriver logger. falling back to stdout logging.")
+		return stdout, func() error { return nil }, nil
 	}
 	return lg, cl, err
 }
@@ -48,6 +112,64 @@ func newJSONLogger() log.Logger {
 	return log.NewJSONLogger(log.NewSyncWriter(os.Stdout))
 }
 
+// logLevels are the supported log levels from lowest to highest.
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+// parseLogLevel returns the index of the given level in logLevels.
+func parseLogLevel(lvl string) (int32, error) {
+	switch strings.ToLower(lvl) {
+	case "":
+		return 0, nil
+	case "warning":
+		return 2, nil
+	}
+	for i, l := range logLevels {
+		if strings.EqualFold(lvl, l) {
+			return int32(i), nil
+		}
+	}
+	return 0, errors.Errorf("invalid log level %q", lvl)
+}
+
+// levelFilter drops leveled logs below a minimum level that can be changed at
+// runtime. Logs without a level are always written.
+type levelFilter struct {
+	next log.Logger
+	// min is the index of the lowest allowed level in logLevels. It is accessed
+	// atomically.
+	min int32
+}
+
+func (f *levelFilter) Log(keyvals ...interface{}) error {
+	if min := atomic.LoadInt32(&f.min); min > 0 {
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			if keyvals[i] != level.Key() {
+				continue
+			}
+			if lvl, err := parseLogLevel(fmt.Sprint(keyvals[i+1])); err == nil && lvl < min {
+				return nil
+			}
+			break
+		}
+	}
+	return f.next.Log(keyvals...)
+}
+
+// level returns the lowest allowed level.
+func (f *levelFilter) level() string {
+	return logLevels[atomic.LoadInt32(&f.min)]
+}
+
+// setLevel will change the lowest allowed level.
+func (f *levelFilter) setLevel(lvl string) error {
+	min, err := parseLogLevel(lvl)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(&f.min, min)
+	return nil
+}
+
 // SetLogger sets log.Logger to the context and returns new context with logger.
 func SetLogger(ctx context.Context, logger log.Logger) context.Context {
 	return context.WithValue(ctx, logKey, logger)
@@ -66,8 +188,8 @@ func Log(ctx context.Context, keyvals ...interface{}) error {
 	return Logger(ctx).Log(keyvals...)
 }
 
-// AddLogKeyVals will add any common HTTP headers or gRPC metadata
-// from the given context to the given logger as fields.
+// AddLogKeyVals will add any common HTTP headers or gRPC metadata, and the
+// request ID, from the given context to the given logger as fields.
 // This is used by the server to initialize the request scopes logger.
 func AddLogKeyVals(ctx context.Context, l log.Logger) log.Logger {
 	// for HTTP requests
@@ -87,6 +209,9 @@ func AddLogKeyVals(ctx context.Context, l log.Logger) log.Logger {
 			l = log.With(l, v, val)
 		}
 	}
+	if id := observe.RequestID(ctx); id != "" {
+		l = log.With(l, "request-id", id)
+	}
 	// for gRPC requests
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
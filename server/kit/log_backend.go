@@ -0,0 +1,129 @@
+package kit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// LoggingService is an optional interface a Service can implement to supply its
+// own logger backend instead of the default JSON or Stackdriver logger. The
+// server will still apply the configured level filter and sampling, and request
+// scoped loggers will still include the keys from AddLogKeyVals.
+//
+// Any log.Logger can be used as a backend and LogFunc can adapt other logging
+// libraries.
+type LoggingService interface {
+	LogBackend() log.Logger
+}
+
+// LogFunc is an adapter to use a logging library, like zap or logrus, as a logger
+// backend. It is called with the level of the log ("debug", "info", "warn",
+// "error" or "" if the log has no level), its "message" value and the rest of its
+// key value pairs. For example, with a zap.SugaredLogger:
+//
+//	func (s *service) LogBackend() log.Logger {
+//		return kit.LogFunc(func(lvl, msg string, keyvals ...interface{}) {
+//			switch lvl {
+//			case "debug":
+//				s.zap.Debugw(msg, keyvals...)
+//			case "warn":
+//				s.zap.Warnw(msg, keyvals...)
+//			case "error":
+//				s.zap.Errorw(msg, keyvals...)
+//			default:
+//				s.zap.Infow(msg, keyvals...)
+//			}
+//		})
+//	}
+type LogFunc func(lvl, msg string, keyvals ...interface{})
+
+// Log implements log.Logger.
+func (f LogFunc) Log(keyvals ...interface{}) error {
+	var (
+		lvl, msg string
+		kvs      = make([]interface{}, 0, len(keyvals))
+	)
+	for i := 0; i < len(keyvals); i += 2 {
+		k := keyvals[i]
+		var v interface{} = log.ErrMissingValue
+		if i+1 < len(keyvals) {
+			v = keyvals[i+1]
+		}
+		switch {
+		case k == level.Key():
+			lvl = fmt.Sprint(v)
+		case k == "message":
+			msg = fmt.Sprint(v)
+		default:
+			kvs = append(kvs, k, v)
+		}
+	}
+	f(lvl, msg, kvs...)
+	return nil
+}
+
+// sampler will drop repeated logs with the same level and message once more than
+// initial have been logged within a second, only letting every thereafter-th one
+// through.
+type sampler struct {
+	next       log.Logger
+	initial    int
+	thereafter int
+	now        func() time.Time
+
+	mu     sync.Mutex
+	window int64
+	counts map[string]int
+}
+
+func newSampler(next log.Logger, initial, thereafter int) *sampler {
+	return &sampler{
+		next:       next,
+		initial:    initial,
+		thereafter: thereafter,
+		now:        time.Now,
+		counts:     map[string]int{},
+	}
+}
+
+func (s *sampler) Log(keyvals ...interface{}) error {
+	var (
+		lvl interface{}
+		msg interface{}
+	)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		switch keyvals[i] {
+		case level.Key():
+			lvl = keyvals[i+1]
+		case "message":
+			msg = keyvals[i+1]
+		}
+	}
+	if msg == nil || lvl == level.ErrorValue() {
+		return s.next.Log(keyvals...)
+	}
+	if s.sample(fmt.Sprint(lvl, ":", msg)) {
+		return s.next.Log(keyvals...)
+	}
+	return nil
+}
+
+// sample will count the log and return true if it should be written.
+func (s *sampler) sample(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w := s.now().Unix(); w != s.window {
+		s.window = w
+		s.counts = map[string]int{}
+	}
+	n := s.counts[key] + 1
+	s.counts[key] = n
+	if n <= s.initial {
+		return true
+	}
+	return s.thereafter > 0 && (n-s.initial)%s.thereafter == 0
+}
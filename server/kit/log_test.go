@@ -0,0 +1,189 @@
+package kit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	"github.com/NYTimes/gizmo/observe"
+)
+
+func TestLoggerLevel(t *testing.T) {
+	var got []string
+	backend := LogFunc(func(lvl, msg string, _ ...interface{}) {
+		got = append(got, lvl+":"+msg)
+	})
+
+	cfg := LoadConfig()
+	cfg.LogLevel = "warn"
+	lg, _, err := newLogger(context.Background(), "", cfg, backend)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	level.Debug(lg).Log("message", "debug")
+	level.Info(lg).Log("message", "info")
+	level.Warn(lg).Log("message", "warn")
+	level.Error(lg).Log("message", "error")
+	lg.Log("message", "no level")
+
+	want := []string{"warn:warn", "error:error", ":no level"}
+	if len(got) != len(want) {
+		t.Fatalf("expected logs %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected log %q, got %q", want[i], got[i])
+		}
+	}
+
+	cfg.LogLevel = "verbose"
+	if _, _, err := newLogger(context.Background(), "", cfg, backend); err == nil {
+		t.Error("expected an error for an invalid log level")
+	}
+	cfg.LogLevel, cfg.LogFormat = "", "xml"
+	if _, _, err := newLogger(context.Background(), "", cfg, nil); err == nil {
+		t.Error("expected an error for an invalid log format")
+	}
+}
+
+func TestNewLoggerEnv(t *testing.T) {
+	defer os.Unsetenv("GIZMO_SKIP_OBSERVE")
+	defer os.Unsetenv("GIZMO_READ_TIMEOUT")
+	defer os.Unsetenv("GIZMO_LOG_SAMPLE_INITIAL")
+	os.Setenv("GIZMO_SKIP_OBSERVE", "true")
+	// unrelated server settings are not read
+	os.Setenv("GIZMO_READ_TIMEOUT", "not a duration")
+
+	if _, _, err := NewLogger(context.Background(), ""); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	os.Setenv("GIZMO_LOG_SAMPLE_INITIAL", "many")
+	if _, _, err := NewLogger(context.Background(), ""); err == nil {
+		t.Error("expected an error for an invalid log setting")
+	}
+}
+
+func TestAddLogKeyValsRequestID(t *testing.T) {
+	ctx := context.WithValue(context.Background(), httptransport.ContextKeyRequestXRequestID, "abc123")
+	ctx = observe.WithRequestID(ctx, "abc123")
+
+	var keyvals []interface{}
+	lg := AddLogKeyVals(ctx, log.LoggerFunc(func(kv ...interface{}) error {
+		keyvals = kv
+		return nil
+	}))
+	lg.Log("message", "hi")
+
+	got := map[interface{}]interface{}{}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		got[keyvals[i]] = keyvals[i+1]
+	}
+	for _, k := range []string{"http-x-request-id", "request-id"} {
+		if got[k] != "abc123" {
+			t.Errorf("expected %s to be logged, got %v", k, keyvals)
+		}
+	}
+}
+
+func TestLoggerSampling(t *testing.T) {
+	var count int
+	s := newSampler(log.LoggerFunc(func(...interface{}) error {
+		count++
+		return nil
+	}), 2, 3)
+	now := time.Unix(0, 0)
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < 8; i++ {
+		level.Info(s).Log("message", "hot path")
+	}
+	// the first 2, then the 5th and 8th
+	if count != 4 {
+		t.Errorf("expected 4 sampled logs, got %d", count)
+	}
+
+	count = 0
+	for i := 0; i < 5; i++ {
+		level.Error(s).Log("message", "hot path")
+		s.Log("no message", i)
+	}
+	if count != 10 {
+		t.Errorf("expected error logs and logs without a message to pass, got %d", count)
+	}
+
+	count = 0
+	now = now.Add(time.Second)
+	level.Info(s).Log("message", "hot path")
+	if count != 1 {
+		t.Errorf("expected sampling to reset every second, got %d", count)
+	}
+}
+
+func TestLoggerBackend(t *testing.T) {
+	svc := &logService{streamService: &streamService{}}
+	svr := NewServer(svc)
+
+	r := httptest.NewRequest(http.MethodGet, "/log", nil)
+	svr.ServeHTTP(httptest.NewRecorder(), r)
+
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	if len(svc.logs) != 1 {
+		t.Fatalf("expected 1 log, got %d", len(svc.logs))
+	}
+	got := svc.logs[0]
+	if got.lvl != "info" || got.msg != "hello" {
+		t.Errorf("expected info log of \"hello\", got %s log of %q", got.lvl, got.msg)
+	}
+	var found bool
+	for i := 0; i+1 < len(got.keyvals); i += 2 {
+		if got.keyvals[i] == "http-path" && got.keyvals[i+1] == "/log" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected request scoped keys in %v", got.keyvals)
+	}
+}
+
+type logEntry struct {
+	lvl, msg string
+	keyvals  []interface{}
+}
+
+type logService struct {
+	*streamService
+
+	mu   sync.Mutex
+	logs []logEntry
+}
+
+func (s *logService) LogBackend() log.Logger {
+	return LogFunc(func(lvl, msg string, keyvals ...interface{}) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		// only keep the logs of the endpoint
+		if msg == "hello" {
+			s.logs = append(s.logs, logEntry{lvl, msg, keyvals})
+		}
+	})
+}
+
+func (s *logService) HTTPEndpoints() map[string]map[string]HTTPEndpoint {
+	return map[string]map[string]HTTPEndpoint{
+		"/log": {"GET": {
+			Endpoint: func(ctx context.Context, _ interface{}) (interface{}, error) {
+				return nil, LogMsg(ctx, "hello")
+			},
+		}},
+	}
+}
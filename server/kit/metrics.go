@@ -0,0 +1,79 @@
+package kit
+
+import (
+	"net/http"
+
+	ocprom "contrib.go.opencensus.io/exporter/prometheus"
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opencensus.io/plugin/ocgrpc"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+var (
+	// HTTPServerRequestCountView counts completed HTTP requests by route, method
+	// and status code.
+	HTTPServerRequestCountView = &view.View{
+		Name:        "gizmo.io/kit/http/server/request_count_by_route",
+		Description: "Count of completed HTTP requests by route, method and status code",
+		Measure:     ochttp.ServerLatency,
+		TagKeys:     []tag.Key{ochttp.KeyServerRoute, ochttp.Method, ochttp.StatusCode},
+		Aggregation: view.Count(),
+	}
+	// HTTPServerLatencyView is the latency distribution of HTTP requests by route,
+	// method and status code.
+	HTTPServerLatencyView = &view.View{
+		Name:        "gizmo.io/kit/http/server/latency_by_route",
+		Description: "Latency distribution of HTTP requests by route, method and status code",
+		Measure:     ochttp.ServerLatency,
+		TagKeys:     []tag.Key{ochttp.KeyServerRoute, ochttp.Method, ochttp.StatusCode},
+		Aggregation: ochttp.DefaultLatencyDistribution,
+	}
+
+	// MetricsViews are the views registered by the server when metrics are
+	// enabled: the OpenCensus HTTP and gRPC server views and the views above,
+	// which are labeled with the route template of each HTTPEndpoint.
+	MetricsViews = append(append([]*view.View{
+		HTTPServerRequestCountView,
+		HTTPServerLatencyView,
+	}, ochttp.DefaultServerViews...), ocgrpc.DefaultServerViews...)
+)
+
+// registerMetrics will register the MetricsViews and serve them in the Prometheus
+// format on the MetricsPath.
+func registerMetrics(cfg Config, mx Router, lg log.Logger) error {
+	if !cfg.EnableMetrics {
+		return nil
+	}
+	h, err := newMetricsHandler(lg)
+	if err != nil {
+		return err
+	}
+	mx.Handle(http.MethodGet, cfg.MetricsPath, h)
+	return nil
+}
+
+func newMetricsHandler(lg log.Logger) (http.Handler, error) {
+	if err := view.Register(MetricsViews...); err != nil {
+		return nil, errors.Wrap(err, "unable to register metrics views")
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	exp, err := ocprom.NewExporter(ocprom.Options{
+		Registry: reg,
+		OnError: func(err error) {
+			lg.Log("error", err, "message", "prometheus exporter encountered an error")
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initiate prometheus exporter")
+	}
+	return exp, nil
+}
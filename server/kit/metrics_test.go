@@ -0,0 +1,75 @@
+package kit_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"google.golang.org/grpc"
+
+	"github.com/NYTimes/gizmo/server/kit"
+	"github.com/NYTimes/gizmo/server/kit/kittest"
+)
+
+func TestKitServerMetrics(t *testing.T) {
+	cfg := kit.LoadConfig()
+	cfg.HTTPAddr, cfg.HTTPPort = "127.0.0.1", 0
+	cfg.EnableMetrics = true
+	svr := kittest.NewServerWithConfig(&metricsService{}, cfg)
+	defer svr.Close()
+
+	for _, name := range []string{"ziggy", "kitty"} {
+		resp, err := svr.Client.Get(svr.URL + "/metrics-test/" + name)
+		if err != nil {
+			t.Fatalf("unable to make request: %s", err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := svr.Client.Get(svr.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("unable to scrape metrics: %s", err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unable to read metrics: %s", err)
+	}
+	metrics := string(b)
+
+	for _, want := range []string{
+		`gizmo_io_kit_http_server_request_count_by_route{http_method="GET",http_server_route="/metrics-test/{name}",http_status="200"} 2`,
+		`gizmo_io_kit_http_server_latency_by_route_count{http_method="GET",http_server_route="/metrics-test/{name}",http_status="200"} 2`,
+		"opencensus_io_http_server_request_count",
+		"go_goroutines",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", want, metrics)
+		}
+	}
+}
+
+type metricsService struct{}
+
+func (s *metricsService) Middleware(e endpoint.Endpoint) endpoint.Endpoint { return e }
+
+func (s *metricsService) HTTPMiddleware(h http.Handler) http.Handler { return h }
+func (s *metricsService) HTTPOptions() []httptransport.ServerOption  { return nil }
+func (s *metricsService) HTTPRouterOptions() []kit.RouterOption      { return nil }
+func (s *metricsService) RPCMiddleware() grpc.UnaryServerInterceptor { return nil }
+func (s *metricsService) RPCOptions() []grpc.ServerOption            { return nil }
+func (s *metricsService) RPCServiceDesc() *grpc.ServiceDesc          { return nil }
+
+func (s *metricsService) HTTPEndpoints() map[string]map[string]kit.HTTPEndpoint {
+	return map[string]map[string]kit.HTTPEndpoint{
+		"/metrics-test/{name}": {"GET": {
+			Endpoint: func(context.Context, interface{}) (interface{}, error) {
+				return "OK", nil
+			},
+		}},
+	}
+}
@@ -0,0 +1,163 @@
+package kit
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// NegotiatedDecoder returns an httptransport.DecodeRequestFunc that decodes the
+// request body into the value returned by newRequest based on the request's
+// Content-Type. Protobuf bodies ("application/x-protobuf") require the value to
+// be a proto.Message. JSON bodies are decoded with protojson if the value is a
+// proto.Message and with encoding/json otherwise. An empty body will leave the
+// value untouched.
+//
+// Bodies that fail to decode will return an InvalidArgument Error and bodies
+// larger than the server's MaxDecompressedBytes will return ErrRequestTooLarge.
+func NegotiatedDecoder(newRequest func() interface{}) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		req := newRequest()
+		b, err := ioutil.ReadAll(r.Body)
+		if err == ErrRequestTooLarge {
+			return nil, err
+		}
+		if err != nil {
+			return nil, Errorf(codes.InvalidArgument, "unable to read request body: %s", err)
+		}
+		if len(b) == 0 {
+			return req, nil
+		}
+
+		pm, isProto := req.(proto.Message)
+		switch {
+		case isProtoContentType(r.Header.Get("Content-Type")):
+			if !isProto {
+				return nil, Errorf(codes.InvalidArgument, "protobuf requests are not supported")
+			}
+			err = proto.Unmarshal(b, pm)
+		case isProto:
+			err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(b, proto.MessageV2(pm))
+		default:
+			err = json.Unmarshal(b, req)
+		}
+		if err != nil {
+			return nil, Errorf(codes.InvalidArgument, "unable to decode request body: %s", err)
+		}
+		return req, nil
+	}
+}
+
+// EncodeNegotiatedResponse is an httptransport.EncodeResponseFunc that encodes
+// the response based on the request's Accept header. proto.Message responses,
+// including ProtoStatusResponse, are encoded as Protobuf if the client prefers
+// "application/x-protobuf" and as protojson otherwise. All other responses,
+// including JSONStatusResponse, are encoded as JSON.
+//
+// If the response implements Headerer, the provided headers will be applied to
+// the response. If the response implements StatusCoder, the provided StatusCode
+// will be used instead of 200.
+func EncodeNegotiatedResponse(ctx context.Context, w http.ResponseWriter, res interface{}) error {
+	msg := res
+	if psr, ok := res.(*ProtoStatusResponse); ok {
+		msg = psr.res
+	}
+	pm, isProto := msg.(proto.Message)
+	if !isProto || pm == nil {
+		return httptransport.EncodeJSONResponse(ctx, w, res)
+	}
+
+	var (
+		b   []byte
+		err error
+	)
+	if acceptsProto(ctx) {
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		b, err = proto.Marshal(pm)
+	} else {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		b, err = protojson.Marshal(proto.MessageV2(pm))
+	}
+	if err != nil {
+		return err
+	}
+
+	if headerer, ok := res.(httptransport.Headerer); ok {
+		for k, values := range headerer.Headers() {
+			for _, v := range values {
+				w.Header().Add(k, v)
+			}
+		}
+	}
+	code := http.StatusOK
+	if sc, ok := res.(httptransport.StatusCoder); ok {
+		code = sc.StatusCode()
+	}
+	w.WriteHeader(code)
+	if code == http.StatusNoContent {
+		return nil
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func isProtoContentType(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mt == "application/x-protobuf" || mt == "application/protobuf"
+}
+
+// acceptsProto returns true if the Accept header of the request in the context
+// prefers Protobuf over JSON. Explicit media types win over wildcards and earlier
+// types win ties.
+func acceptsProto(ctx context.Context) bool {
+	accept, _ := ctx.Value(httptransport.ContextKeyRequestAccept).(string)
+	if accept == "" {
+		return false
+	}
+
+	var (
+		best    float64
+		exact   bool
+		isProto bool
+	)
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qs, 64); err != nil {
+				continue
+			}
+		}
+
+		var proto, wildcard bool
+		switch mt {
+		case "application/x-protobuf", "application/protobuf":
+			proto = true
+		case "application/json":
+		case "*/*", "application/*":
+			wildcard = true
+		default:
+			continue
+		}
+		if q <= 0 || q < best || (q == best && (exact || wildcard)) {
+			continue
+		}
+		best, exact, isProto = q, !wildcard, proto
+	}
+	return isProto
+}
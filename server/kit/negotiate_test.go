@@ -0,0 +1,191 @@
+package kit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/NYTimes/gizmo/server/kit"
+)
+
+func TestEncodeNegotiatedResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		res    interface{}
+
+		wantCode  int
+		wantType  string
+		wantProto bool
+	}{
+		{
+			name:     "proto as JSON by default",
+			res:      testCat,
+			wantCode: http.StatusOK,
+			wantType: "application/json; charset=utf-8",
+		},
+		{
+			name:      "proto as protobuf",
+			accept:    "application/x-protobuf",
+			res:       testCat,
+			wantCode:  http.StatusOK,
+			wantType:  "application/x-protobuf",
+			wantProto: true,
+		},
+		{
+			name:     "JSON preferred by quality",
+			accept:   "application/x-protobuf;q=0.5, application/json",
+			res:      testCat,
+			wantCode: http.StatusOK,
+			wantType: "application/json; charset=utf-8",
+		},
+		{
+			name:      "protobuf preferred over wildcard",
+			accept:    "*/*, application/protobuf",
+			res:       testCat,
+			wantCode:  http.StatusOK,
+			wantType:  "application/x-protobuf",
+			wantProto: true,
+		},
+		{
+			name:      "proto status response as protobuf",
+			accept:    "application/x-protobuf",
+			res:       kit.NewProtoStatusResponse(testCat, http.StatusCreated),
+			wantCode:  http.StatusCreated,
+			wantType:  "application/x-protobuf",
+			wantProto: true,
+		},
+		{
+			name:     "proto status response as JSON",
+			res:      kit.NewProtoStatusResponse(testCat, http.StatusAccepted),
+			wantCode: http.StatusAccepted,
+			wantType: "application/json; charset=utf-8",
+		},
+		{
+			name:     "JSON status response",
+			accept:   "application/x-protobuf",
+			res:      kit.NewJSONStatusResponse(testCat, http.StatusCreated),
+			wantCode: http.StatusCreated,
+			wantType: "application/json; charset=utf-8",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(),
+				httptransport.ContextKeyRequestAccept, test.accept)
+			w := httptest.NewRecorder()
+
+			if err := kit.EncodeNegotiatedResponse(ctx, w, test.res); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if w.Code != test.wantCode {
+				t.Errorf("expected status code of %d, got %d", test.wantCode, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != test.wantType {
+				t.Errorf("expected content type %q, got %q", test.wantType, ct)
+			}
+
+			var got Cat
+			if test.wantProto {
+				if err := proto.Unmarshal(w.Body.Bytes(), &got); err != nil {
+					t.Fatalf("unable to decode protobuf response: %s", err)
+				}
+			} else if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("unable to decode JSON response: %s", err)
+			}
+			if !proto.Equal(&got, testCat) {
+				t.Errorf("expected response %v, got %v", testCat, &got)
+			}
+		})
+	}
+}
+
+func TestNegotiatedDecoder(t *testing.T) {
+	pb, err := proto.Marshal(testCat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		newRequest  func() interface{}
+
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name:        "protobuf",
+			contentType: "application/x-protobuf",
+			body:        pb,
+			newRequest:  func() interface{} { return &Cat{} },
+			want:        testCat,
+		},
+		{
+			name:        "protojson",
+			contentType: "application/json",
+			body:        []byte(`{"Name":"Ziggy","Breed":"American Shorthair","Age":12,"unknown":1}`),
+			newRequest:  func() interface{} { return &Cat{} },
+			want:        testCat,
+		},
+		{
+			name:        "JSON",
+			contentType: "application/json",
+			body:        []byte(`{"name":"ziggy"}`),
+			newRequest:  func() interface{} { return &struct{ Name string }{} },
+			want:        &struct{ Name string }{Name: "ziggy"},
+		},
+		{
+			name:        "protobuf into non-proto",
+			contentType: "application/x-protobuf",
+			body:        pb,
+			newRequest:  func() interface{} { return &struct{ Name string }{} },
+			wantErr:     true,
+		},
+		{
+			name:        "bad JSON",
+			contentType: "application/json",
+			body:        []byte(`{"Name":`),
+			newRequest:  func() interface{} { return &Cat{} },
+			wantErr:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(test.body))
+			r.Header.Set("Content-Type", test.contentType)
+
+			got, err := kit.NegotiatedDecoder(test.newRequest)(context.Background(), r)
+			if test.wantErr {
+				if e, ok := err.(*kit.Error); !ok || e.StatusCode() != http.StatusBadRequest {
+					t.Errorf("expected a bad request error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if pm, ok := got.(proto.Message); ok {
+				if !proto.Equal(pm, test.want.(proto.Message)) {
+					t.Errorf("expected request %v, got %v", test.want, got)
+				}
+				return
+			}
+			gb, _ := json.Marshal(got)
+			wb, _ := json.Marshal(test.want)
+			if !bytes.Equal(gb, wb) {
+				t.Errorf("expected request %s, got %s", wb, gb)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+// Package ratelimit provides token bucket rate limiting for gizmo kit servers.
+//
+// The middleware returned by NewMiddleware can be applied in a kit.Service's
+// Middleware method to limit both HTTP and gRPC requests:
+//
+//	func (s *service) Middleware(ep endpoint.Endpoint) endpoint.Endpoint {
+//	    return s.limiter(ep)
+//	}
+//
+// Limited requests will receive a 429 Too Many Requests response with a
+// Retry-After header over HTTP and a ResourceExhausted status with a
+// `google.rpc.RetryInfo` detail over gRPC.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/gizmo/server/kit"
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/ptypes"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/tag"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Limit is the size and refill rate of a token bucket.
+type Limit struct {
+	// Rate is the number of tokens added to the bucket per second.
+	Rate float64
+	// Burst is the maximum number of tokens in the bucket. It will be at least 1.
+	Burst int
+}
+
+// PerSecond will return a Limit of n requests per second with a burst of n.
+func PerSecond(n int) Limit {
+	return Limit{Rate: float64(n), Burst: n}
+}
+
+// PerMinute will return a Limit of n requests per minute with a burst of n.
+func PerMinute(n int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: n}
+}
+
+func (l Limit) burst() int {
+	if l.Burst < 1 {
+		return 1
+	}
+	return l.Burst
+}
+
+// KeyFunc returns the key of the bucket the request should take a token from.
+// Requests with an empty key are not limited.
+type KeyFunc func(ctx context.Context, req interface{}) (string, error)
+
+// ByRoute will limit requests per HTTP method and route template or per gRPC
+// method.
+func ByRoute(ctx context.Context, _ interface{}) (string, error) {
+	if method, ok := grpc.Method(ctx); ok {
+		return method, nil
+	}
+	method, _ := ctx.Value(httptransport.ContextKeyRequestMethod).(string)
+	route := ""
+	if tags := tag.FromContext(ctx); tags != nil {
+		route, _ = tags.Value(ochttp.KeyServerRoute)
+	}
+	if route == "" {
+		route, _ = ctx.Value(httptransport.ContextKeyRequestPath).(string)
+	}
+	return method + " " + route, nil
+}
+
+// ByClientIP will limit requests per client IP address, the remote address of
+// the connection. Use ByForwardedClientIP for servers behind proxies.
+func ByClientIP(ctx context.Context, _ interface{}) (string, error) {
+	return remoteIP(ctx), nil
+}
+
+// ByForwardedClientIP will limit requests per client IP address for servers
+// behind the given number of trusted proxies, like load balancers, that append
+// the address of their client to the X-Forwarded-For header of HTTP requests.
+// The address trustedProxies entries from the end of the header is used, as
+// entries before it can be set by clients. If the header has fewer entries, the
+// first one is used, and without the header the remote address of the
+// connection is used.
+func ByForwardedClientIP(trustedProxies int) KeyFunc {
+	return func(ctx context.Context, _ interface{}) (string, error) {
+		xff, _ := ctx.Value(httptransport.ContextKeyRequestXForwardedFor).(string)
+		if xff == "" || trustedProxies < 1 {
+			return remoteIP(ctx), nil
+		}
+		ips := strings.Split(xff, ",")
+		i := len(ips) - trustedProxies
+		if i < 0 {
+			i = 0
+		}
+		return strings.TrimSpace(ips[i]), nil
+	}
+}
+
+// remoteIP returns the host of the remote address of an HTTP request or RPC.
+func remoteIP(ctx context.Context) string {
+	addr, _ := ctx.Value(httptransport.ContextKeyRequestRemoteAddr).(string)
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr = p.Addr.String()
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// ByPrincipal will limit requests per authenticated principal, as returned by
+// the given func. Unauthenticated requests, with an empty principal, are not
+// limited.
+func ByPrincipal(principal func(context.Context) string) KeyFunc {
+	return func(ctx context.Context, _ interface{}) (string, error) {
+		return principal(ctx), nil
+	}
+}
+
+// Join will limit requests by the combination of the keys of the given funcs.
+// If any key is empty, the request is not limited.
+func Join(keys ...KeyFunc) KeyFunc {
+	return func(ctx context.Context, req interface{}) (string, error) {
+		parts := make([]string, len(keys))
+		for i, key := range keys {
+			k, err := key(ctx, req)
+			if err != nil || k == "" {
+				return "", err
+			}
+			parts[i] = k
+		}
+		return strings.Join(parts, "|"), nil
+	}
+}
+
+// NewMiddleware will return a middleware that limits requests to the given
+// Limit per key. If the Store returns an error, the request is allowed and the
+// error is logged.
+func NewMiddleware(store Store, limit Limit, key KeyFunc) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			k, err := key(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			if k == "" {
+				return next(ctx, req)
+			}
+			ok, retryAfter, err := store.Take(ctx, k, limit)
+			if err != nil {
+				kit.LogErrorMsg(ctx, err, "unable to check rate limit")
+				return next(ctx, req)
+			}
+			if !ok {
+				return nil, &Error{RetryAfter: retryAfter}
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// Error is returned by the middleware when a request has been rate limited.
+type Error struct {
+	// RetryAfter is the time until the next request will be allowed or 0 if
+	// unknown.
+	RetryAfter time.Duration
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	if e.RetryAfter <= 0 {
+		return "rate limit exceeded"
+	}
+	return fmt.Sprintf("rate limit exceeded, retry after %s", e.RetryAfter)
+}
+
+// StatusCode implements httptransport.StatusCoder.
+func (e *Error) StatusCode() int {
+	return http.StatusTooManyRequests
+}
+
+// Headers implements httptransport.Headerer and sets the Retry-After header.
+func (e *Error) Headers() http.Header {
+	h := http.Header{}
+	if e.RetryAfter > 0 {
+		h.Set("Retry-After", strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds()))))
+	}
+	return h
+}
+
+// GRPCStatus returns a ResourceExhausted status with a RetryInfo detail.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(codes.ResourceExhausted, e.Error())
+	if e.RetryAfter <= 0 {
+		return st
+	}
+	if dst, err := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: ptypes.DurationProto(e.RetryAfter),
+	}); err == nil {
+		return dst
+	}
+	return st
+}
@@ -0,0 +1,135 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/NYTimes/gizmo/server/kit"
+	"github.com/NYTimes/gizmo/server/kit/ratelimit"
+)
+
+func TestMiddlewareHTTP(t *testing.T) {
+	svc := &service{
+		limit: ratelimit.NewMiddleware(ratelimit.NewMemoryStore(), ratelimit.PerMinute(1),
+			ratelimit.Join(ratelimit.ByRoute, ratelimit.ByClientIP)),
+	}
+	svr := kit.NewServer(svc)
+
+	do := func(path, ip string) *http.Response {
+		r := httptest.NewRequest(http.MethodGet, "http://localhost:8080"+path, nil)
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		svr.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	if res := do("/cat/1", "10.0.0.1"); res.StatusCode != http.StatusOK {
+		t.Fatalf("expected first request to succeed, got %d", res.StatusCode)
+	}
+	// the same route template is limited
+	res := do("/cat/2", "10.0.0.1")
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected status code of 429, got %d", res.StatusCode)
+	}
+	if ra := res.Header.Get("Retry-After"); ra != "60" {
+		t.Errorf("expected Retry-After of 60, got %q", ra)
+	}
+	if e := kit.DecodeErrorResponse(res); e.Code != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted error, got %s", e)
+	}
+
+	// other clients and routes have their own limits
+	if res := do("/cat/1", "10.0.0.2"); res.StatusCode != http.StatusOK {
+		t.Errorf("expected other client to succeed, got %d", res.StatusCode)
+	}
+	if res := do("/dog", "10.0.0.1"); res.StatusCode != http.StatusOK {
+		t.Errorf("expected other route to succeed, got %d", res.StatusCode)
+	}
+}
+
+func TestByClientIP(t *testing.T) {
+	ctx := context.WithValue(context.Background(), httptransport.ContextKeyRequestRemoteAddr, "10.0.0.1:1234")
+	ctx = context.WithValue(ctx, httptransport.ContextKeyRequestXForwardedFor, "1.1.1.1, 2.2.2.2, 3.3.3.3")
+
+	tests := []struct {
+		name string
+		key  ratelimit.KeyFunc
+		ctx  context.Context
+		want string
+	}{
+		{"remote address", ratelimit.ByClientIP, ctx, "10.0.0.1"},
+		{"no trusted proxies", ratelimit.ByForwardedClientIP(0), ctx, "10.0.0.1"},
+		{"one trusted proxy", ratelimit.ByForwardedClientIP(1), ctx, "3.3.3.3"},
+		{"two trusted proxies", ratelimit.ByForwardedClientIP(2), ctx, "2.2.2.2"},
+		{"more trusted proxies than entries", ratelimit.ByForwardedClientIP(5), ctx, "1.1.1.1"},
+		{"no header", ratelimit.ByForwardedClientIP(1),
+			context.WithValue(ctx, httptransport.ContextKeyRequestXForwardedFor, ""), "10.0.0.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.key(test.ctx, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != test.want {
+				t.Errorf("expected key %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestErrorGRPCStatus(t *testing.T) {
+	err := &ratelimit.Error{RetryAfter: 1500 * time.Millisecond}
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted, got %s", st.Code())
+	}
+	details := st.Details()
+	if len(details) != 1 {
+		t.Fatalf("expected 1 detail, got %d", len(details))
+	}
+	ri, ok := details[0].(*errdetails.RetryInfo)
+	if !ok {
+		t.Fatalf("expected RetryInfo detail, got %T", details[0])
+	}
+	if d, _ := ptypes.Duration(ri.RetryDelay); d != err.RetryAfter {
+		t.Errorf("expected retry delay of %s, got %s", err.RetryAfter, d)
+	}
+	if ra := err.Headers().Get("Retry-After"); ra != "2" {
+		t.Errorf("expected Retry-After of 2, got %q", ra)
+	}
+}
+
+type service struct {
+	limit endpoint.Middleware
+}
+
+func (s *service) Middleware(e endpoint.Endpoint) endpoint.Endpoint {
+	return s.limit(e)
+}
+
+func (s *service) HTTPMiddleware(h http.Handler) http.Handler { return h }
+func (s *service) HTTPOptions() []httptransport.ServerOption  { return nil }
+func (s *service) HTTPRouterOptions() []kit.RouterOption      { return nil }
+func (s *service) RPCMiddleware() grpc.UnaryServerInterceptor { return nil }
+func (s *service) RPCOptions() []grpc.ServerOption            { return nil }
+func (s *service) RPCServiceDesc() *grpc.ServiceDesc          { return nil }
+
+func (s *service) HTTPEndpoints() map[string]map[string]kit.HTTPEndpoint {
+	ok := func(context.Context, interface{}) (interface{}, error) { return "OK", nil }
+	return map[string]map[string]kit.HTTPEndpoint{
+		"/cat/{id}": {"GET": {Endpoint: ok}},
+		"/dog":      {"GET": {Endpoint: ok}},
+	}
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Store keeps the token buckets of each rate limit key. MemoryStore keeps them
+// in process. To share limits across server instances, implement Store on top of
+// a distributed store like Redis or Memcached.
+type Store interface {
+	// Take will remove a token from the bucket for the given key, creating a full
+	// bucket if there is none. If the bucket is empty, it will return false and the
+	// time until the next token is available.
+	Take(ctx context.Context, key string, limit Limit) (ok bool, retryAfter time.Duration, err error)
+}
+
+// MemoryStore is an in-memory Store. Buckets that have refilled are removed
+// periodically so idle keys do not accumulate.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	now func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+}
+
+// sweepInterval is how often a MemoryStore removes refilled buckets.
+const sweepInterval = time.Minute
+
+var _ Store = &MemoryStore{}
+
+// NewMemoryStore will return an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Take implements Store.
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	burst := float64(limit.burst())
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		s.buckets[key] = b
+	}
+
+	// refill the bucket for the time passed since the last take
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed.Seconds()*limit.Rate)
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		if limit.Rate <= 0 {
+			return false, 0, nil
+		}
+		wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+		return false, wait, nil
+	}
+	b.tokens--
+	if limit.Rate > 0 {
+		b.full = now.Add(time.Duration((burst - b.tokens) / limit.Rate * float64(time.Second)))
+	} else {
+		b.full = time.Time{}
+	}
+	return true, 0, nil
+}
+
+// sweep will remove buckets that have refilled since they are equivalent to
+// having no bucket.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !b.full.IsZero() && !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+	limit := Limit{Rate: 2, Burst: 2}
+
+	take := func(key string) (bool, time.Duration) {
+		ok, retry, err := s.Take(ctx, key, limit)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return ok, retry
+	}
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := take("a"); !ok {
+			t.Fatalf("expected take %d to be allowed", i)
+		}
+	}
+	ok, retry := take("a")
+	if ok {
+		t.Fatal("expected take to be limited once the burst is used")
+	}
+	if retry != 500*time.Millisecond {
+		t.Errorf("expected retry after 500ms, got %s", retry)
+	}
+
+	// other keys have their own bucket
+	if ok, _ := take("b"); !ok {
+		t.Error("expected a different key to be allowed")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := take("a"); !ok {
+		t.Error("expected take to be allowed after the bucket refilled")
+	}
+	if ok, _ := take("a"); ok {
+		t.Error("expected take to be limited again")
+	}
+
+	// refilled buckets are removed on the next sweep
+	now = now.Add(2 * sweepInterval)
+	take("c")
+	if _, found := s.buckets["a"]; found {
+		t.Error("expected refilled bucket to be removed")
+	}
+	if _, found := s.buckets["c"]; !found {
+		t.Error("expected the new bucket to be kept")
+	}
+}
@@ -0,0 +1,89 @@
+package kit
+
+import (
+	"context"
+	"net/http"
+
+	uuid "github.com/nu7hatch/gouuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/NYTimes/gizmo/observe"
+)
+
+// maxRequestIDLength is the longest request ID accepted from clients. Longer or
+// malformed IDs are replaced to keep logs readable.
+const maxRequestIDLength = 128
+
+// RequestID returns the ID of the request being served. The server will use the
+// X-Request-Id header or x-request-id metadata sent by the client, or generate a
+// new ID if absent, and echo it back in the response headers. The ID is added to
+// request scoped logs and propagated on calls made with the request context by
+// the kit clients and gizmo pubsub publishers.
+func RequestID(ctx context.Context) string {
+	return observe.RequestID(ctx)
+}
+
+// httpRequestID will add the ID of the request to its headers, the response
+// headers and the returned context.
+func httpRequestID(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+	id := r.Header.Get(observe.RequestIDKey)
+	if !validRequestID(id) {
+		id = newRequestID()
+		// so it is available to go-kit and HTTP middleware
+		r.Header.Set(observe.RequestIDKey, id)
+	}
+	w.Header().Set(observe.RequestIDKey, id)
+	return observe.WithRequestID(ctx, id)
+}
+
+// rpcRequestID will return a context with the ID of the RPC, along with the ID
+// to send back to the client.
+func rpcRequestID(ctx context.Context) (context.Context, string) {
+	var id string
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ids := md.Get(observe.RequestIDKey); len(ids) > 0 {
+		id = ids[0]
+	}
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	return observe.WithRequestID(ctx, id), id
+}
+
+// setRPCRequestID will add the ID of the RPC to the context and response
+// headers of a unary RPC.
+func setRPCRequestID(ctx context.Context) context.Context {
+	ctx, id := rpcRequestID(ctx)
+	grpc.SetHeader(ctx, metadata.Pairs(observe.RequestIDKey, id))
+	return ctx
+}
+
+// setStreamRequestID will add the ID of the RPC to the context and response
+// headers of a stream.
+func setStreamRequestID(ctx context.Context, ss grpc.ServerStream) context.Context {
+	ctx, id := rpcRequestID(ctx)
+	ss.SetHeader(metadata.Pairs(observe.RequestIDKey, id))
+	return ctx
+}
+
+func newRequestID() string {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return ""
+	}
+	return id.String()
+}
+
+// validRequestID returns true for non-empty IDs of printable ASCII characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
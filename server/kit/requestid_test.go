@@ -0,0 +1,80 @@
+package kit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/NYTimes/gizmo/server/kit"
+	"github.com/NYTimes/gizmo/server/kit/kittest"
+)
+
+func TestKitServerRequestID(t *testing.T) {
+	svr := kittest.NewServer(&requestIDService{})
+	defer svr.Close()
+
+	get := func(id string) (header, seen string) {
+		r, _ := http.NewRequest(http.MethodGet, svr.URL+"/svc/id", nil)
+		if id != "" {
+			r.Header.Set("X-Request-Id", id)
+		}
+		resp, err := svr.Client.Do(r)
+		if err != nil {
+			t.Fatalf("unable to make request: %s", err)
+		}
+		defer resp.Body.Close()
+		json.NewDecoder(resp.Body).Decode(&seen)
+		return resp.Header.Get("X-Request-Id"), seen
+	}
+
+	if header, seen := get("abc123"); header != "abc123" || seen != "abc123" {
+		t.Errorf("expected the given request ID, got %q in the header and %q in the context",
+			header, seen)
+	}
+	for _, id := range []string{"", strings.Repeat("x", 200), "a b"} {
+		header, seen := get(id)
+		if header == "" || header == id || header != seen {
+			t.Errorf("expected a generated request ID instead of %q, got %q in the header and %q in the context",
+				id, header, seen)
+		}
+	}
+
+	// gRPC
+	client := NewKitTestServiceClient(svr.Conn)
+	var md metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "xyz")
+	if _, err := client.GetCatName(ctx, &GetCatNameRequest{Name: "ziggy"}, grpc.Header(&md)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := md.Get("x-request-id"); len(got) != 1 || got[0] != "xyz" {
+		t.Errorf("expected the given request ID in the response header, got %v", got)
+	}
+	md = nil
+	if _, err := client.GetCatName(context.Background(), &GetCatNameRequest{Name: "ziggy"}, grpc.Header(&md)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := md.Get("x-request-id"); len(got) != 1 || got[0] == "" {
+		t.Errorf("expected a generated request ID in the response header, got %v", got)
+	}
+}
+
+type requestIDService struct {
+	server
+}
+
+func (s *requestIDService) HTTPEndpoints() map[string]map[string]kit.HTTPEndpoint {
+	return map[string]map[string]kit.HTTPEndpoint{
+		"/svc/id": {
+			"GET": {
+				Endpoint: func(ctx context.Context, _ interface{}) (interface{}, error) {
+					return kit.RequestID(ctx), nil
+				},
+			},
+		},
+	}
+}
@@ -87,16 +87,24 @@ func (l sdLogger) Log(keyvals ...interface{}) error {
 		svrty = logging.Warning
 	}
 
+	// render access logs as request logs
+	var httpReq *logging.HTTPRequest
+	if hr, ok := kvs[httpRequestLogKey].(*httpRequestLog); ok {
+		httpReq = hr.stackdriver()
+		delete(kvs, httpRequestLogKey)
+	}
+
 	payload, err := json.Marshal(kvs)
 	if err != nil {
 		return err
 	}
 
 	l.lgr.Log(logging.Entry{
-		Severity: svrty,
-		Payload:  json.RawMessage(payload),
-		Trace:    traceID,
-		Resource: l.monRes,
+		Severity:    svrty,
+		Payload:     json.RawMessage(payload),
+		Trace:       traceID,
+		Resource:    l.monRes,
+		HTTPRequest: httpReq,
 	})
 	return nil
 }
@@ -105,7 +113,10 @@ func (l sdLogger) getTraceID(traceCtx string) string {
 	return "projects/" + l.project + "/traces/" + strings.Split(traceCtx, "/")[0]
 }
 
-const cloudTraceLogKey = "cloud-trace"
+const (
+	cloudTraceLogKey  = "cloud-trace"
+	httpRequestLogKey = "httpRequest"
+)
 
 ///////////////////////////////////////////////////
 // below funcs are straight up copied out of go-kit/kit/log:
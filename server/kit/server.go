@@ -1,6 +1,7 @@
 package kit // import "github.com/NYTimes/gizmo/server/kit"
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
@@ -12,7 +13,7 @@ import (
 func Run(service Service) error {
 	svr := NewServer(service)
 
-	if err := svr.start(); err != nil {
+	if err := svr.Start(context.Background()); err != nil {
 		return err
 	}
 
@@ -20,5 +21,5 @@ func Run(service Service) error {
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGTERM, syscall.SIGINT, syscall.SIGKILL)
 	svr.logger.Log("received signal", <-ch)
-	return svr.stop()
+	return svr.Stop(context.Background())
 }
@@ -1,6 +1,7 @@
 package kit
 
 import (
+	"context"
 	"net/http"
 
 	"google.golang.org/grpc"
@@ -11,6 +12,10 @@ import (
 
 // HTTPEndpoint encapsulates everything required to build
 // an endpoint hosted on a kit server.
+//
+// If no Decoder is set, the *http.Request will be passed to the Endpoint. If no
+// Encoder is set, responses will be encoded as JSON. Use NegotiatedDecoder and
+// EncodeNegotiatedResponse to support both JSON and Protobuf clients.
 type HTTPEndpoint struct {
 	Endpoint endpoint.Endpoint
 	Decoder  httptransport.DecodeRequestFunc
@@ -71,10 +76,6 @@ type Service interface {
 	// for easy integration with 3rd party grpc.UnaryServerInterceptors like
 	// http://godoc.org/cloud.google.com/go/trace#Client.GRPCServerInterceptor
 	//
-	// The underlying kit server already uses the one available grpc.UnaryInterceptor
-	// grpc.ServerOption so attempting to pass your own in this Service's RPCOptions()
-	// will cause a panic at startup.
-	//
 	// If you want to apply multiple RPC middlewares,
 	// we recommend using:
 	// http://godoc.org/github.com/grpc-ecosystem/go-grpc-middleware#ChainUnaryServer
@@ -86,14 +87,38 @@ type Service interface {
 
 	// RPCOptions are for service-wide gRPC server options.
 	//
-	// The underlying kit server already uses the one available grpc.UnaryInterceptor
-	// grpc.ServerOption so attempting to pass your own in this method will cause a panic
-	// at startup. We recommend using RPCMiddleware() to fill this need.
+	// Any grpc.UnaryInterceptor or grpc.StreamInterceptor passed here will be
+	// called before the kit server's interceptors. We recommend using
+	// RPCMiddleware() and RPCStreamService's RPCStreamMiddleware() to fill this
+	// need.
 	RPCOptions() []grpc.ServerOption
 }
 
+// RPCStreamService is an optional interface a Service can implement to add
+// service-wide middleware for streaming RPCs registered via RPCServiceDesc.
+//
+// Streaming RPCs always receive a request-scoped logger in their stream context
+// and pass through the Service's Middleware, with the grpc.ServerStream given as
+// the request, before any RPCStreamMiddleware is called.
+type RPCStreamService interface {
+	// RPCStreamMiddleware is for any service-wide gRPC streaming middleware
+	// for easy integration with 3rd party grpc.StreamServerInterceptors.
+	//
+	// If you want to apply multiple RPC stream middlewares,
+	// we recommend using:
+	// http://godoc.org/github.com/grpc-ecosystem/go-grpc-middleware#ChainStreamServer
+	RPCStreamMiddleware() grpc.StreamServerInterceptor
+}
+
 // Shutdowner allows your service to shutdown gracefully when http server stops.
 // This may used when service has any background task which needs to be completed gracefully.
 type Shutdowner interface {
 	Shutdown()
 }
+
+// ContextShutdowner is like Shutdowner but its Shutdown method will be called
+// with a context that expires at the server's shutdown deadline. Any error it
+// returns will be logged and returned from Run.
+type ContextShutdowner interface {
+	Shutdown(ctx context.Context) error
+}
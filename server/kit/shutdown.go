@@ -0,0 +1,128 @@
+package kit
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// shutdownLogInterval is how often the number of in-flight requests is logged
+// while the server waits for them to complete.
+var shutdownLogInterval = 5 * time.Second
+
+// trackRequest will count a request as in flight until the returned func is
+// called.
+func (s *Server) trackRequest() func() {
+	atomic.AddInt64(&s.inFlight, 1)
+	return func() { atomic.AddInt64(&s.inFlight, -1) }
+}
+
+// InFlight returns the number of HTTP and gRPC requests currently being served.
+func (s *Server) InFlight() int64 {
+	return atomic.LoadInt64(&s.inFlight)
+}
+
+// shutdown will gracefully stop the server:
+//
+//  1. readiness checks start failing
+//  2. requests are served for ShutdownDrainDelay so load balancers can
+//     deregister the server
+//  3. the HTTP and gRPC servers stop accepting connections and wait for
+//     in-flight requests until ShutdownTimeout or the given context is done,
+//     after which any remaining connections are closed
+//  4. the admin server is stopped and the service's Shutdown hook is called
+func (s *Server) shutdown(ctx context.Context) error {
+	s.ready.shutdown()
+
+	if d := s.cfg.ShutdownDrainDelay; d > 0 {
+		s.logger.Log("message", "draining server before shutdown",
+			"delay", d.String(), "in_flight", s.InFlight())
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	s.logger.Log("message", "shutting down server", "in_flight", s.InFlight())
+	done := make(chan struct{})
+	go s.logInFlight(done)
+	err := s.stopServers(ctx)
+	close(done)
+	s.logger.Log("message", "server stopped", "in_flight", s.InFlight())
+	s.stopAdmin(ctx)
+
+	var serr error
+	switch svc := s.svc.(type) {
+	case ContextShutdowner:
+		serr = svc.Shutdown(ctx)
+	case Shutdowner:
+		svc.Shutdown()
+	}
+	if serr != nil {
+		s.logger.Log("error", serr, "message", "service encountered an error during shutdown")
+		if err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// stopServers will stop the HTTP and gRPC servers and wait for in-flight
+// requests to complete until the context is done.
+func (s *Server) stopServers(ctx context.Context) error {
+	var rpcStopped chan struct{}
+	if s.gsvr != nil && !s.rpcOverHTTP {
+		rpcStopped = make(chan struct{})
+		go func() {
+			s.gsvr.GracefulStop()
+			close(rpcStopped)
+		}()
+	}
+
+	err := s.svr.Shutdown(ctx)
+	if err != nil {
+		s.logger.Log("error", err, "in_flight", s.InFlight(),
+			"message", "HTTP server did not stop in time - closing connections")
+		s.svr.Close()
+	}
+
+	if rpcStopped != nil {
+		select {
+		case <-rpcStopped:
+		case <-ctx.Done():
+			s.logger.Log("error", ctx.Err(), "in_flight", s.InFlight(),
+				"message", "gRPC server did not stop in time - closing connections")
+			s.gsvr.Stop()
+			<-rpcStopped
+			if err == nil {
+				err = ctx.Err()
+			}
+		}
+	}
+	if s.rpcOverHTTP {
+		// all gRPC requests were drained by the HTTP server
+		s.gsvr.Stop()
+	}
+	return err
+}
+
+// logInFlight will periodically log the number of in-flight requests until done
+// is closed.
+func (s *Server) logInFlight(done chan struct{}) {
+	t := time.NewTicker(shutdownLogInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			s.logger.Log("message", "waiting for in-flight requests to complete",
+				"in_flight", s.InFlight())
+		}
+	}
+}
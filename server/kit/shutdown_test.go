@@ -0,0 +1,153 @@
+package kit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestKitServerShutdownDrain(t *testing.T) {
+	port := freePort(t)
+	os.Setenv("GIZMO_SINGLE_PORT", "true")
+	os.Setenv("GIZMO_SHUTDOWN_DRAIN_DELAY", "500ms")
+	os.Setenv("HTTP_ADDR", "127.0.0.1")
+	os.Setenv("HTTP_PORT", strconv.Itoa(port))
+	defer os.Unsetenv("GIZMO_SINGLE_PORT")
+	defer os.Unsetenv("GIZMO_SHUTDOWN_DRAIN_DELAY")
+	defer os.Unsetenv("HTTP_ADDR")
+	defer os.Unsetenv("HTTP_PORT")
+
+	svc := &shutdownService{
+		streamService: &streamService{},
+		err:           errors.New("unable to flush"),
+		called:        make(chan struct{}),
+	}
+	svr := NewServer(svc)
+	if err := svr.Start(context.Background()); err != nil {
+		t.Fatalf("unable to start server: %s", err)
+	}
+	addr := "http://127.0.0.1:" + strconv.Itoa(port)
+
+	stopped := make(chan error)
+	go func() { stopped <- svr.Stop(context.Background()) }()
+
+	// wait for readiness to fail while the server keeps serving
+	deadline := time.Now().Add(time.Second)
+	for {
+		resp, err := http.Get(addr + "/readyz")
+		if err != nil {
+			t.Fatalf("unable to make request while draining: %s", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected readiness to fail during the drain delay")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	resp, err := http.Get(addr + "/healthz")
+	if err != nil {
+		t.Fatalf("unable to make request while draining: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status code of 200 while draining, got %d", resp.StatusCode)
+	}
+	select {
+	case <-svc.called:
+		t.Error("expected service to shut down after the drain delay")
+	default:
+	}
+
+	if err := <-stopped; err != svc.err {
+		t.Errorf("expected the service's shutdown error, got %v", err)
+	}
+	select {
+	case <-svc.called:
+	default:
+		t.Fatal("expected service Shutdown to be called")
+	}
+	if !svc.hasDeadline {
+		t.Error("expected service Shutdown context to have a deadline")
+	}
+}
+
+func TestKitServerShutdownDeadline(t *testing.T) {
+	httpPort, rpcPort := freePort(t), freePort(t)
+	os.Setenv("GIZMO_SHUTDOWN_TIMEOUT", "200ms")
+	os.Setenv("HTTP_ADDR", "127.0.0.1")
+	os.Setenv("HTTP_PORT", strconv.Itoa(httpPort))
+	os.Setenv("RPC_PORT", strconv.Itoa(rpcPort))
+	defer os.Unsetenv("GIZMO_SHUTDOWN_TIMEOUT")
+	defer os.Unsetenv("HTTP_ADDR")
+	defer os.Unsetenv("HTTP_PORT")
+	defer os.Unsetenv("RPC_PORT")
+
+	svr := NewServer(&streamService{})
+	if err := svr.Start(context.Background()); err != nil {
+		t.Fatalf("unable to start server: %s", err)
+	}
+
+	cc, err := grpc.Dial("127.0.0.1:"+strconv.Itoa(rpcPort), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("unable to init gRPC connection: %s", err)
+	}
+	defer cc.Close()
+
+	// open a stream that never completes
+	stream, err := cc.NewStream(context.Background(), &streamServiceDesc.Streams[0],
+		"/kit_test.StreamService/Echo")
+	if err != nil {
+		t.Fatalf("unable to open stream: %s", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for svr.InFlight() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 1 request in flight, got %d", svr.InFlight())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	start := time.Now()
+	if err := svr.Stop(context.Background()); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded error, got %v", err)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("expected shutdown to stop at the deadline, took %s", took)
+	}
+	if err := stream.RecvMsg(new(interface{})); err == nil {
+		t.Error("expected stream to be closed")
+	}
+}
+
+func freePort(t *testing.T) int {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return lis.Addr().(*net.TCPAddr).Port
+}
+
+type shutdownService struct {
+	*streamService
+	err error
+
+	called      chan struct{}
+	hasDeadline bool
+}
+
+func (s *shutdownService) Shutdown(ctx context.Context) error {
+	_, s.hasDeadline = ctx.Deadline()
+	close(s.called)
+	return s.err
+}
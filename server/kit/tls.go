@@ -0,0 +1,183 @@
+package kit
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// PeerCertificate will return the verified client certificate of the current
+// HTTP or gRPC request. It will return nil if the server is not configured to
+// verify client certificates via TLS_CLIENT_CA or if the client did not present a
+// certificate.
+func PeerCertificate(ctx context.Context) *x509.Certificate {
+	cert, _ := ctx.Value(ContextKeyPeerCertificate).(*x509.Certificate)
+	return cert
+}
+
+// verifiedCert returns the leaf of the first verified chain of the connection.
+func verifiedCert(cs *tls.ConnectionState) *x509.Certificate {
+	if cs == nil || len(cs.VerifiedChains) == 0 || len(cs.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return cs.VerifiedChains[0][0]
+}
+
+// withRPCPeerCertificate will add the verified client certificate of the gRPC
+// peer to the context, if there is one.
+func withRPCPeerCertificate(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ctx
+	}
+	if cert := verifiedCert(&info.State); cert != nil {
+		return context.WithValue(ctx, ContextKeyPeerCertificate, cert)
+	}
+	return ctx
+}
+
+// newTLSConfig will return the TLS config for the server or nil if no
+// certificate is configured.
+func newTLSConfig(cfg Config) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		if cfg.TLSClientCAFile != "" {
+			return nil, errors.New("TLS_CLIENT_CA requires TLS_CERT and TLS_KEY")
+		}
+		return nil, nil
+	}
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return nil, errors.New("both TLS_CERT and TLS_KEY are required to enable TLS")
+	}
+
+	cr := &certReloader{certFile: cfg.TLSCertFile, keyFile: cfg.TLSKeyFile}
+	if err := cr.reload(); err != nil {
+		return nil, err
+	}
+	tcfg := &tls.Config{
+		GetCertificate: cr.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		pool, err := readCertPool(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid client CA")
+		}
+		tcfg.ClientCAs = pool
+		tcfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tcfg, nil
+}
+
+// readCertPool will read a pool of PEM encoded certificates from a file.
+func readCertPool(file string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read CA file")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificates found in CA file")
+	}
+	return pool, nil
+}
+
+// certCheckInterval is how often the certificate files are checked for changes.
+var certCheckInterval = 5 * time.Second
+
+// certReloader will serve a certificate and key pair from disk, reloading it
+// when either file is modified.
+type certReloader struct {
+	certFile, keyFile string
+
+	// cert holds the *tls.Certificate being served.
+	cert atomic.Value
+	// nextCheck is the UnixNano time of the next check for changes. It is
+	// accessed atomically.
+	nextCheck int64
+
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+// GetCertificate implements the tls.Config hook and will serve the current
+// certificate. The files are checked for changes at most every
+// certCheckInterval by a single handshake while the others are served the
+// current certificate. If the new files fail to load, the previous certificate
+// will continue to be served.
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	now := time.Now().UnixNano()
+	next := atomic.LoadInt64(&c.nextCheck)
+	if now >= next && atomic.CompareAndSwapInt64(&c.nextCheck, next, now+int64(certCheckInterval)) {
+		c.mu.Lock()
+		if mod, err := c.lastModified(); err == nil && mod.After(c.modTime) {
+			c.reloadLocked()
+		}
+		c.mu.Unlock()
+	}
+	return c.cert.Load().(*tls.Certificate), nil
+}
+
+func (c *certReloader) reload() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reloadLocked()
+}
+
+func (c *certReloader) reloadLocked() error {
+	mod, err := c.lastModified()
+	if err != nil {
+		return errors.Wrap(err, "unable to stat TLS files")
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "unable to load TLS key pair")
+	}
+	c.cert.Store(&cert)
+	c.modTime = mod
+	atomic.StoreInt64(&c.nextCheck, time.Now().Add(certCheckInterval).UnixNano())
+	return nil
+}
+
+func (c *certReloader) lastModified() (time.Time, error) {
+	var last time.Time
+	for _, name := range []string{c.certFile, c.keyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return last, err
+		}
+		if fi.ModTime().After(last) {
+			last = fi.ModTime()
+		}
+	}
+	return last, nil
+}
+
+// grpcHandler will send HTTP/2 requests with a gRPC content-type to the gRPC
+// server and everything else to the given handler. It is used to share a TLS
+// port between both servers.
+func grpcHandler(gsvr *grpc.Server, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			gsvr.ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
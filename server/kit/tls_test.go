@@ -0,0 +1,268 @@
+package kit
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func TestKitServerMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kit-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, "ca", nil)
+	srvCert := newTestCert(t, "server", ca)
+	clientCert := newTestCert(t, "client", ca)
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	caFile := filepath.Join(dir, "ca.pem")
+	srvCert.write(t, certFile, keyFile)
+	ca.write(t, caFile, filepath.Join(dir, "ca-key.pem"))
+
+	// check for new certificates on every handshake
+	defer func(d time.Duration) { certCheckInterval = d }(certCheckInterval)
+	certCheckInterval = 0
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := lis.Addr().(*net.TCPAddr).Port
+	lis.Close()
+
+	env := map[string]string{
+		"GIZMO_SINGLE_PORT": "true",
+		"HTTP_ADDR":         "127.0.0.1",
+		"HTTP_PORT":         strconv.Itoa(port),
+		"TLS_CERT":          certFile,
+		"TLS_KEY":           keyFile,
+		"TLS_CLIENT_CA":     caFile,
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	svc := &tlsService{streamService: &streamService{}}
+	svr := NewServer(svc)
+	if err := svr.Start(context.Background()); err != nil {
+		t.Fatalf("unable to start server: %s", err)
+	}
+	addr := "127.0.0.1:" + strconv.Itoa(port)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	clientTLS := &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{clientCert.tlsCert()},
+	}
+
+	// HTTP with a client certificate
+	hc := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}
+	resp, err := hc.Get("https://" + addr + "/whoami")
+	if err != nil {
+		t.Fatalf("unable to make HTTPS request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status code of 200, got %d", resp.StatusCode)
+	}
+	if svc.httpPeer != "client" {
+		t.Errorf("expected HTTP peer name %q, got %q", "client", svc.httpPeer)
+	}
+
+	// HTTP without a client certificate
+	hc = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	if _, err = hc.Get("https://" + addr + "/whoami"); err == nil {
+		t.Error("expected request without client certificate to fail")
+	}
+
+	// gRPC with a client certificate
+	cc, err := grpc.Dial(addr, grpc.WithTransportCredentials(credentials.NewTLS(clientTLS)))
+	if err != nil {
+		t.Fatalf("unable to init gRPC connection: %s", err)
+	}
+	stream, err := cc.NewStream(context.Background(), &streamServiceDesc.Streams[0],
+		"/kit_test.StreamService/Echo")
+	if err != nil {
+		t.Fatalf("unable to open stream: %s", err)
+	}
+	if err = stream.SendMsg(&wrappers.StringValue{Value: "ziggy"}); err != nil {
+		t.Fatalf("unable to send message: %s", err)
+	}
+	stream.CloseSend()
+	var got wrappers.StringValue
+	if err = stream.RecvMsg(&got); err != nil {
+		t.Fatalf("unable to receive message: %s", err)
+	}
+	cc.Close()
+	if svc.peerName != "client" {
+		t.Errorf("expected gRPC peer name %q, got %q", "client", svc.peerName)
+	}
+
+	// replace the server certificate and expect new connections to use it
+	newTestCert(t, "reloaded", ca).write(t, certFile, keyFile)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	os.Chtimes(keyFile, future, future)
+
+	hc = &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}
+	resp, err = hc.Get("https://" + addr + "/whoami")
+	if err != nil {
+		t.Fatalf("unable to make HTTPS request: %s", err)
+	}
+	resp.Body.Close()
+	if name := resp.TLS.PeerCertificates[0].Subject.CommonName; name != "reloaded" {
+		t.Errorf("expected reloaded server certificate, got %q", name)
+	}
+
+	if err := svr.Stop(context.Background()); err != nil {
+		t.Errorf("unexpected error on shutdown: %s", err)
+	}
+}
+
+func TestCertReloaderCheckInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kit-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(d time.Duration) { certCheckInterval = d }(certCheckInterval)
+	certCheckInterval = time.Hour
+
+	ca := newTestCert(t, "ca", nil)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	newTestCert(t, "first", ca).write(t, certFile, keyFile)
+
+	cr := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := cr.reload(); err != nil {
+		t.Fatal(err)
+	}
+	name := func() string {
+		cert, err := cr.GetCertificate(nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+
+	newTestCert(t, "second", ca).write(t, certFile, keyFile)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	os.Chtimes(keyFile, future, future)
+
+	if got := name(); got != "first" {
+		t.Errorf("expected the files to not be checked before the interval, got %q", got)
+	}
+	atomic.StoreInt64(&cr.nextCheck, 0)
+	if got := name(); got != "second" {
+		t.Errorf("expected the certificate to be reloaded after the interval, got %q", got)
+	}
+}
+
+type tlsService struct {
+	*streamService
+	httpPeer string
+}
+
+func (s *tlsService) HTTPEndpoints() map[string]map[string]HTTPEndpoint {
+	return map[string]map[string]HTTPEndpoint{
+		"/whoami": {
+			"GET": {
+				Endpoint: endpoint.Endpoint(func(ctx context.Context, _ interface{}) (interface{}, error) {
+					if cert := PeerCertificate(ctx); cert != nil {
+						s.httpPeer = cert.Subject.CommonName
+					}
+					return "OK", nil
+				}),
+			},
+		},
+	}
+}
+
+type testCert struct {
+	cert *x509.Certificate
+	der  []byte
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert will create a certificate signed by the parent or a self-signed
+// CA certificate if parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, der: der, key: key}
+}
+
+func (c *testCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func (c *testCert) write(t *testing.T, certFile, keyFile string) {
+	kb, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
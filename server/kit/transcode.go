@@ -0,0 +1,366 @@
+package kit
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/golang/protobuf/proto"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// HTTPTranscodingService is an optional interface a Service can implement to have
+// the kit server derive HTTP endpoints from the unary methods of its RPCServiceDesc,
+// so a single implementation can serve both transports.
+//
+// Transcoded endpoints decode the request message from the URL path variables,
+// query parameters and a JSON or Protobuf body, pass it through the Service's
+// Middleware, RPCMiddleware and gRPC method and encode the response message as JSON, or as
+// Protobuf if the request's Accept header asks for "application/x-protobuf".
+// Routes already defined in HTTPEndpoints take precedence.
+//
+// Path variables require a Router that supports "{var}" templates, like the
+// default Gorilla router.
+type HTTPTranscodingService interface {
+	// HTTPTranscodingRules returns the HTTP rule for each gRPC method name that
+	// should be served over HTTP. For example:
+	//
+	//    return map[string]kit.TranscodingRule{
+	//        "GetCatName": {Method: "GET", Path: "/svc/cat/{name}"},
+	//    }
+	//
+	// If no rules are returned, they will be read from the `google.api.http`
+	// annotations in the proto definition of the service.
+	HTTPTranscodingRules() map[string]TranscodingRule
+}
+
+// TranscodingRule describes how an HTTP request maps to a gRPC method. It mirrors
+// the fields of the `google.api.HttpRule` proto annotation.
+type TranscodingRule struct {
+	// Method is the HTTP method of the route.
+	Method string
+	// Path is the URL path template of the route. Variables are written as
+	// "{field}" or "{field=pattern}" and will set the request message field of the
+	// same (dot separated) name.
+	Path string
+	// Body is the name of the request message field the HTTP body is decoded
+	// into. "*" will decode the body into the entire request message. If empty,
+	// no body is expected and any query parameters are used to set fields.
+	Body string
+}
+
+// registerTranscoding will register HTTP endpoints for each transcoding rule of
+// the service. Any routes found in skip are left untouched.
+func (s *Server) registerTranscoding(svc Service, ts HTTPTranscodingService, gdesc *grpc.ServiceDesc, opts []httptransport.ServerOption, skip map[string]bool) error {
+	rules := map[string][]TranscodingRule{}
+	for method, rule := range ts.HTTPTranscodingRules() {
+		rules[method] = []TranscodingRule{rule}
+	}
+	if len(rules) == 0 {
+		var err error
+		rules, err = annotatedRules(gdesc.ServiceName)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the same interceptors as the gRPC server, so checks like auth also apply
+	inter := grpc.UnaryServerInterceptor(validateInterceptor)
+	if mw := svc.RPCMiddleware(); mw != nil {
+		inter = grpc_middleware.ChainUnaryServer(mw, validateInterceptor)
+	}
+
+	for _, md := range gdesc.Methods {
+		info := &grpc.UnaryServerInfo{
+			Server:     svc,
+			FullMethod: "/" + gdesc.ServiceName + "/" + md.MethodName,
+		}
+		for _, rule := range rules[md.MethodName] {
+			if skip[rule.Method+" "+rule.Path] {
+				continue
+			}
+			path, err := transcodePath(rule.Path)
+			if err != nil {
+				return errors.Wrapf(err, "invalid path for method %s", md.MethodName)
+			}
+			s.mux.Handle(rule.Method, path,
+				withRoute(
+					httptransport.NewServer(
+						svc.Middleware(transcodedEndpoint(svc, md.Handler, rule, info, inter)),
+						basicDecoder,
+						EncodeNegotiatedResponse,
+						opts...), rule.Path))
+		}
+	}
+	return nil
+}
+
+// annotatedRules will look up the registered proto definition of the given
+// service and return the `google.api.http` rules of each method.
+func annotatedRules(serviceName string) (map[string][]TranscodingRule, error) {
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to find proto definition for %s", serviceName)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, errors.Errorf("%s is not a service", serviceName)
+	}
+
+	rules := map[string][]TranscodingRule{}
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		opts, ok := md.Options().(proto.Message)
+		if !ok || opts == nil || !proto.HasExtension(opts, annotations.E_Http) {
+			continue
+		}
+		ext, err := proto.GetExtension(opts, annotations.E_Http)
+		if err != nil {
+			return nil, err
+		}
+		hr, ok := ext.(*annotations.HttpRule)
+		if !ok {
+			continue
+		}
+		name := string(md.Name())
+		for _, r := range append([]*annotations.HttpRule{hr}, hr.GetAdditionalBindings()...) {
+			rule := TranscodingRule{Body: r.GetBody()}
+			switch {
+			case r.GetGet() != "":
+				rule.Method, rule.Path = http.MethodGet, r.GetGet()
+			case r.GetPut() != "":
+				rule.Method, rule.Path = http.MethodPut, r.GetPut()
+			case r.GetPost() != "":
+				rule.Method, rule.Path = http.MethodPost, r.GetPost()
+			case r.GetDelete() != "":
+				rule.Method, rule.Path = http.MethodDelete, r.GetDelete()
+			case r.GetPatch() != "":
+				rule.Method, rule.Path = http.MethodPatch, r.GetPatch()
+			case r.GetCustom() != nil:
+				rule.Method, rule.Path = r.GetCustom().GetKind(), r.GetCustom().GetPath()
+			default:
+				continue
+			}
+			rules[name] = append(rules[name], rule)
+		}
+	}
+	return rules, nil
+}
+
+var pathVarRE = regexp.MustCompile(`\{([^}=]+)(?:=([^}]*))?\}`)
+
+// transcodePath will convert a `google.api.http` path template into a Gorilla
+// style path template. "{name=shelves/*}" will become "{name:shelves/[^/]+}".
+func transcodePath(tmpl string) (string, error) {
+	if !strings.HasPrefix(tmpl, "/") {
+		return "", errors.Errorf("path %q must start with '/'", tmpl)
+	}
+	return pathVarRE.ReplaceAllStringFunc(tmpl, func(v string) string {
+		m := pathVarRE.FindStringSubmatch(v)
+		if m[2] == "" {
+			return "{" + m[1] + "}"
+		}
+		segs := strings.Split(m[2], "/")
+		for i, seg := range segs {
+			switch seg {
+			case "*":
+				segs[i] = "[^/]+"
+			case "**":
+				segs[i] = ".+"
+			default:
+				segs[i] = regexp.QuoteMeta(seg)
+			}
+		}
+		return "{" + m[1] + ":" + strings.Join(segs, "/") + "}"
+	}), nil
+}
+
+// transcodedEndpoint returns an endpoint that will call the given gRPC method
+// handler, through the interceptor, with a request message decoded from the
+// *http.Request.
+func transcodedEndpoint(svc Service, handler func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error), rule TranscodingRule, info *grpc.UnaryServerInfo, inter grpc.UnaryServerInterceptor) endpoint.Endpoint {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		r := req.(*http.Request)
+		return handler(svc, ctx, func(in interface{}) error {
+			if err := decodeTranscodedRequest(r, rule, in); err != nil {
+				if errors.Cause(err) == ErrRequestTooLarge {
+					return ErrRequestTooLarge
+				}
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+			return nil
+		}, func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, h grpc.UnaryHandler) (interface{}, error) {
+			return inter(ctx, req, info, h)
+		})
+	}
+}
+
+func decodeTranscodedRequest(r *http.Request, rule TranscodingRule, in interface{}) error {
+	pm, ok := in.(proto.Message)
+	if !ok {
+		return errors.New("request does not implement proto.Message")
+	}
+	msg := proto.MessageReflect(pm)
+
+	if rule.Body != "" {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return errors.Wrap(err, "unable to read request body")
+		}
+		target := msg
+		if rule.Body != "*" {
+			fd, parent, err := findField(msg, rule.Body)
+			if err != nil {
+				return err
+			}
+			if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+				return errors.Errorf("body field %q must be a message", rule.Body)
+			}
+			target = parent.Mutable(fd).Message()
+		}
+		if len(b) > 0 {
+			if isProtoContentType(r.Header.Get("Content-Type")) {
+				err = protov2.Unmarshal(b, target.Interface())
+			} else {
+				err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(b, target.Interface())
+			}
+			if err != nil {
+				return errors.Wrap(err, "unable to decode request body")
+			}
+		}
+	}
+
+	for name, val := range Vars(r) {
+		if err := setField(msg, name, []string{val}); err != nil {
+			return err
+		}
+	}
+
+	if rule.Body == "*" {
+		return nil
+	}
+	for name, vals := range r.URL.Query() {
+		// unknown query parameters are ignored
+		if _, _, err := findField(msg, name); err != nil {
+			continue
+		}
+		if err := setField(msg, name, vals); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findField will walk the dot separated path and return the descriptor of the
+// last field along with the message containing it. Field names may be given as
+// their proto or JSON names.
+func findField(msg protoreflect.Message, path string) (protoreflect.FieldDescriptor, protoreflect.Message, error) {
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		fields := msg.Descriptor().Fields()
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = fields.ByJSONName(name)
+		}
+		if fd == nil {
+			return nil, nil, errors.Errorf("unknown field %q", path)
+		}
+		if i == len(names)-1 {
+			return fd, msg, nil
+		}
+		if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+			return nil, nil, errors.Errorf("field %q is not a message", name)
+		}
+		msg = msg.Mutable(fd).Message()
+	}
+	return nil, nil, errors.Errorf("unknown field %q", path)
+}
+
+// setField will parse the given string values and set them on the field at the
+// given path.
+func setField(msg protoreflect.Message, path string, vals []string) error {
+	fd, parent, err := findField(msg, path)
+	if err != nil {
+		return err
+	}
+	if fd.IsMap() || (fd.Message() != nil) {
+		return errors.Errorf("field %q cannot be set from a string", path)
+	}
+	if fd.IsList() {
+		list := parent.Mutable(fd).List()
+		for _, val := range vals {
+			v, err := parseScalar(fd, val)
+			if err != nil {
+				return errors.Wrapf(err, "invalid value for field %q", path)
+			}
+			list.Append(v)
+		}
+		return nil
+	}
+	if len(vals) == 0 {
+		return nil
+	}
+	v, err := parseScalar(fd, vals[len(vals)-1])
+	if err != nil {
+		return errors.Wrapf(err, "invalid value for field %q", path)
+	}
+	parent.Set(fd, v)
+	return nil
+}
+
+func parseScalar(fd protoreflect.FieldDescriptor, val string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(val), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(val)
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		i, err := strconv.ParseInt(val, 10, 32)
+		return protoreflect.ValueOfInt32(int32(i)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		i, err := strconv.ParseInt(val, 10, 64)
+		return protoreflect.ValueOfInt64(i), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		i, err := strconv.ParseUint(val, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(i)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		i, err := strconv.ParseUint(val, 10, 64)
+		return protoreflect.ValueOfUint64(i), err
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(val, 32)
+		return protoreflect.ValueOfFloat32(float32(f)), err
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(val, 64)
+		return protoreflect.ValueOfFloat64(f), err
+	case protoreflect.BytesKind:
+		b, err := base64.StdEncoding.DecodeString(val)
+		if err != nil {
+			b, err = base64.URLEncoding.DecodeString(val)
+		}
+		return protoreflect.ValueOfBytes(b), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(val)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		i, err := strconv.ParseInt(val, 10, 32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)), err
+	}
+	return protoreflect.Value{}, errors.Errorf("unsupported field kind %s", fd.Kind())
+}
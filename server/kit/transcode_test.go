@@ -0,0 +1,164 @@
+package kit_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	ocontext "golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/NYTimes/gizmo/server/kit"
+)
+
+func TestKitServerHTTPTranscoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  map[string]kit.TranscodingRule
+		deny   bool
+		method string
+		path   string
+		body   string
+		accept string
+
+		wantCode int
+		wantCat  *Cat
+		wantErr  codes.Code
+	}{
+		{
+			name:     "annotated path var",
+			method:   http.MethodGet,
+			path:     "/svc/cat/ziggy",
+			wantCode: http.StatusOK,
+			wantCat:  &Cat{Name: "ziggy", Breed: "American Shorthair", Age: 12},
+		},
+		{
+			name:     "annotated error",
+			method:   http.MethodGet,
+			path:     "/svc/cat/garfield",
+			wantCode: http.StatusNotFound,
+			wantErr:  codes.NotFound,
+		},
+		{
+			name:     "annotated protobuf response",
+			method:   http.MethodGet,
+			path:     "/svc/cat/ziggy",
+			accept:   "application/x-protobuf",
+			wantCode: http.StatusOK,
+			wantCat:  &Cat{Name: "ziggy", Breed: "American Shorthair", Age: 12},
+		},
+		{
+			name: "rule with body",
+			rules: map[string]kit.TranscodingRule{
+				"GetCatName": {Method: http.MethodPost, Path: "/svc/cats", Body: "*"},
+			},
+			method:   http.MethodPost,
+			path:     "/svc/cats",
+			body:     `{"name":"ziggy","unknown":true}`,
+			wantCode: http.StatusOK,
+			wantCat:  &Cat{Name: "ziggy", Breed: "American Shorthair", Age: 12},
+		},
+		{
+			name: "rule with bad body",
+			rules: map[string]kit.TranscodingRule{
+				"GetCatName": {Method: http.MethodPost, Path: "/svc/cats", Body: "*"},
+			},
+			method:   http.MethodPost,
+			path:     "/svc/cats",
+			body:     `{"name":`,
+			wantCode: http.StatusBadRequest,
+			wantErr:  codes.InvalidArgument,
+		},
+		{
+			name: "rule with query params",
+			rules: map[string]kit.TranscodingRule{
+				"GetCatName": {Method: http.MethodGet, Path: "/svc/cats"},
+			},
+			method:   http.MethodGet,
+			path:     "/svc/cats?name=ziggy&other=1",
+			wantCode: http.StatusOK,
+			wantCat:  &Cat{Name: "ziggy", Breed: "American Shorthair", Age: 12},
+		},
+		{
+			name:     "rejected by RPC middleware",
+			deny:     true,
+			method:   http.MethodGet,
+			path:     "/svc/cat/ziggy",
+			wantCode: http.StatusForbidden,
+			wantErr:  codes.PermissionDenied,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svr := kit.NewServer(&transcodingServer{rules: test.rules, deny: test.deny})
+
+			r := httptest.NewRequest(test.method, "http://localhost:8080"+test.path,
+				strings.NewReader(test.body))
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			w := httptest.NewRecorder()
+			svr.ServeHTTP(w, r)
+
+			if w.Code != test.wantCode {
+				t.Fatalf("expected status code of %d, got %d: %s", test.wantCode, w.Code, w.Body)
+			}
+
+			if test.wantErr != codes.OK {
+				got := kit.DecodeErrorResponse(w.Result())
+				if got.Code != test.wantErr {
+					t.Errorf("expected error code %s, got %s", test.wantErr, got.Code)
+				}
+				return
+			}
+
+			var got Cat
+			if test.accept != "" {
+				if err := proto.Unmarshal(w.Body.Bytes(), &got); err != nil {
+					t.Fatalf("unable to decode protobuf response: %s", err)
+				}
+			} else if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("unable to decode JSON response: %s", err)
+			}
+			if !proto.Equal(&got, test.wantCat) {
+				t.Errorf("expected response %v, got %v", test.wantCat, &got)
+			}
+		})
+	}
+}
+
+type transcodingServer struct {
+	server
+	rules map[string]kit.TranscodingRule
+	deny  bool
+}
+
+func (s *transcodingServer) RPCMiddleware() grpc.UnaryServerInterceptor {
+	return func(ctx ocontext.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if s.deny && info.FullMethod == "/kit_test.Kit_testService/GetCatName" {
+			return nil, status.Error(codes.PermissionDenied, "denied")
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (s *transcodingServer) HTTPEndpoints() map[string]map[string]kit.HTTPEndpoint {
+	return nil
+}
+
+func (s *transcodingServer) HTTPTranscodingRules() map[string]kit.TranscodingRule {
+	return s.rules
+}
+
+func (s *transcodingServer) GetCatName(ctx ocontext.Context, r *GetCatNameRequest) (*Cat, error) {
+	if r.Name != "ziggy" {
+		return nil, status.Errorf(codes.NotFound, "cat %q not found", r.Name)
+	}
+	return &Cat{Name: r.Name, Breed: testCat.Breed, Age: testCat.Age}, nil
+}
@@ -0,0 +1,108 @@
+package kit
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Validator can be implemented by decoded HTTP requests and gRPC request messages
+// to have the kit server validate them before they reach the endpoint. Messages
+// generated by protoc-gen-validate implement this interface.
+//
+// If Validate returns an *Error, it will be returned as is. Any other error will
+// be returned as an InvalidArgument Error with a `google.rpc.BadRequest` detail
+// listing the field violations. Errors that have `Field() string` and
+// `Reason() string` methods, like the ones generated by protoc-gen-validate, are
+// reported as violations of that field.
+type Validator interface {
+	Validate() error
+}
+
+// validatorAll is implemented by messages generated by newer versions of
+// protoc-gen-validate and will return all violations instead of the first.
+type validatorAll interface {
+	ValidateAll() error
+}
+
+// validateRequest will validate the request if it implements Validator.
+func validateRequest(req interface{}) error {
+	var err error
+	switch v := req.(type) {
+	case validatorAll:
+		err = v.ValidateAll()
+	case Validator:
+		err = v.Validate()
+	default:
+		return nil
+	}
+	if err == nil {
+		return nil
+	}
+	if e, ok := errors.Cause(err).(*Error); ok {
+		return e
+	}
+
+	br := &errdetails.BadRequest{}
+	errs := []error{err}
+	if multi, ok := err.(interface{ AllErrors() []error }); ok {
+		errs = multi.AllErrors()
+	}
+	for _, err := range errs {
+		if fv := fieldViolation(err); fv != nil {
+			br.FieldViolations = append(br.FieldViolations, fv)
+		}
+	}
+	if len(br.FieldViolations) == 0 {
+		return NewError(codes.InvalidArgument, err.Error())
+	}
+	return NewError(codes.InvalidArgument, err.Error(), br)
+}
+
+type fieldError interface {
+	Field() string
+	Reason() string
+}
+
+// fieldViolation will convert a field error to a violation, following any
+// nested field errors of embedded messages.
+func fieldViolation(err error) *errdetails.BadRequest_FieldViolation {
+	fe, ok := err.(fieldError)
+	if !ok {
+		return nil
+	}
+	fv := &errdetails.BadRequest_FieldViolation{
+		Field:       fe.Field(),
+		Description: fe.Reason(),
+	}
+	if c, ok := err.(interface{ Cause() error }); ok && c.Cause() != nil {
+		if nested := fieldViolation(c.Cause()); nested != nil {
+			fv.Field += "." + nested.Field
+			fv.Description = nested.Description
+		}
+	}
+	return fv
+}
+
+// validateEndpoint will validate requests before passing them to the endpoint.
+func validateEndpoint(e endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		if err := validateRequest(req); err != nil {
+			return nil, err
+		}
+		return e(ctx, req)
+	}
+}
+
+// validateInterceptor will validate gRPC requests before passing them to the
+// method handler.
+func validateInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := validateRequest(req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
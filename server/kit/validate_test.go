@@ -0,0 +1,119 @@
+package kit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+
+	"github.com/NYTimes/gizmo/server/kit"
+)
+
+func TestKitServerValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+
+		wantCode  int
+		wantField string
+	}{
+		{
+			name:     "valid HTTP endpoint",
+			path:     "/svc/validate?name=ziggy",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:      "invalid HTTP endpoint",
+			path:      "/svc/validate",
+			wantCode:  http.StatusBadRequest,
+			wantField: "name",
+		},
+		{
+			name:     "valid transcoded method",
+			path:     "/svc/cats?name=ziggy",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:      "invalid transcoded method",
+			path:      "/svc/cats",
+			wantCode:  http.StatusBadRequest,
+			wantField: "name",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svr := kit.NewServer(&validatingServer{transcodingServer{
+				rules: map[string]kit.TranscodingRule{
+					"GetCatName": {Method: http.MethodGet, Path: "/svc/cats"},
+				},
+			}})
+
+			r := httptest.NewRequest(http.MethodGet, "http://localhost:8080"+test.path, nil)
+			w := httptest.NewRecorder()
+			svr.ServeHTTP(w, r)
+
+			res := w.Result()
+			if res.StatusCode != test.wantCode {
+				t.Fatalf("expected status code of %d, got %d", test.wantCode, res.StatusCode)
+			}
+			if test.wantField == "" {
+				return
+			}
+
+			got := kit.DecodeErrorResponse(res)
+			if got.Code != codes.InvalidArgument {
+				t.Errorf("expected code %s, got %s", codes.InvalidArgument, got.Code)
+			}
+			if len(got.Details) != 1 {
+				t.Fatalf("expected 1 detail, got %d", len(got.Details))
+			}
+			br, ok := got.Details[0].(*errdetails.BadRequest)
+			if !ok || len(br.FieldViolations) != 1 {
+				t.Fatalf("expected a single field violation, got %v", got.Details[0])
+			}
+			if f := br.FieldViolations[0].Field; f != test.wantField {
+				t.Errorf("expected violation of field %q, got %q", test.wantField, f)
+			}
+		})
+	}
+}
+
+// Validate will reject requests without a name, similar to protoc-gen-validate.
+func (m *GetCatNameRequest) Validate() error {
+	if m.Name == "" {
+		return fieldError{field: "name", reason: "value length must be at least 1 runes"}
+	}
+	return nil
+}
+
+type fieldError struct {
+	field, reason string
+}
+
+func (e fieldError) Field() string  { return e.field }
+func (e fieldError) Reason() string { return e.reason }
+func (e fieldError) Error() string  { return "invalid " + e.field + ": " + e.reason }
+
+type validatingServer struct {
+	transcodingServer
+}
+
+func (s *validatingServer) HTTPEndpoints() map[string]map[string]kit.HTTPEndpoint {
+	return map[string]map[string]kit.HTTPEndpoint{
+		"/svc/validate": {
+			"GET": {
+				Endpoint: endpoint.Endpoint(func(ctx context.Context, r interface{}) (interface{}, error) {
+					return s.GetCatName(ctx, r.(*GetCatNameRequest))
+				}),
+				Decoder: func(ctx context.Context, r *http.Request) (interface{}, error) {
+					return &GetCatNameRequest{Name: r.URL.Query().Get("name")}, nil
+				},
+			},
+		},
+	}
+}